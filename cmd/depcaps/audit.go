@@ -0,0 +1,96 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+
+	"github.com/breml/depcaps/pkg/depcaps"
+)
+
+const auditUsage = `usage: depcaps audit [flags] module@version
+
+audit lists the capabilities of every exported package of the module version,
+e.g. github.com/google/uuid@v1.3.1, before it is added to the dependencies.
+The module is analyzed in a temporary main module with the modules of the
+local module cache or the -proxy directory only, without network access. The
+capabilities are evaluated against the -config file.
+
+Flags:
+`
+
+// runAudit runs the audit command with args and returns the exit code, which
+// is 3, if the highest severity of the violations is at least -failseverity.
+func runAudit(args []string) int {
+	flags := flag.NewFlagSet("audit", flag.ExitOnError)
+	settings := &depcaps.LinterSettings{}
+	flags.Var(settings, "config", "depcaps linter settings config file")
+	proxy := flags.String("proxy", "", "directory in the layout of a GOPROXY, which is used instead of the local module cache")
+	cacheDir := flags.String("cachedir", "", "directory of the capabilities cache (default: depcaps in the user cache directory)")
+	noCache := flags.Bool("nocache", false, "disable the capabilities cache")
+	format := flags.String("format", "text", "output format: text, sarif, json, markdown or html")
+	failSeverity := flags.String("failseverity", "error", "lowest severity of violations, which fails with exit code 3: error, warning or info")
+	flags.Usage = func() {
+		fmt.Fprint(flags.Output(), auditUsage)
+		flags.PrintDefaults()
+	}
+	_ = flags.Parse(args) // flags uses flag.ExitOnError
+
+	if flags.NArg() != 1 {
+		flags.Usage()
+		return 1
+	}
+
+	threshold, err := depcaps.ParseSeverity(*failSeverity)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "depcaps audit: -failseverity: %v\n", err)
+		return 1
+	}
+
+	opts := []depcaps.Option{depcaps.WithCacheDir(*cacheDir)}
+	if *noCache {
+		opts = append(opts, depcaps.WithoutCache())
+	}
+	if *proxy != "" {
+		dir, err := filepath.Abs(*proxy)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "depcaps audit: -proxy: %v\n", err)
+			return 1
+		}
+		proxyURL := url.URL{Scheme: "file", Path: filepath.ToSlash(dir)}
+		opts = append(opts, depcaps.WithEnv("GOPROXY="+proxyURL.String(), "GOSUMDB=off"))
+	}
+
+	report, err := depcaps.Audit(context.Background(), flags.Arg(0), settings, opts...)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "depcaps audit: %v\n", err)
+		return 1
+	}
+
+	switch *format {
+	case "text":
+		err = depcaps.WriteAudit(os.Stdout, report.Findings)
+	case "sarif":
+		err = depcaps.WriteSARIF(os.Stdout, ".", report.Findings)
+	case "json":
+		err = report.WriteJSON(os.Stdout)
+	case "markdown":
+		err = report.WriteMarkdown(os.Stdout)
+	case "html":
+		err = report.WriteHTML(os.Stdout)
+	default:
+		err = fmt.Errorf("unknown output format %q", *format)
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "depcaps audit: %v\n", err)
+		return 1
+	}
+
+	if depcaps.HighestSeverity(report.Findings) >= threshold {
+		return 3
+	}
+	return 0
+}
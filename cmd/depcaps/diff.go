@@ -0,0 +1,234 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"golang.org/x/mod/modfile"
+
+	"github.com/breml/depcaps/pkg/depcaps"
+	"github.com/breml/depcaps/pkg/module"
+)
+
+const diffUsage = `usage: depcaps diff [flags] OLD NEW [packages]
+
+diff compares the capabilities of the dependency modules between two states
+of go.mod and go.sum of the main module. OLD and NEW are either a go.mod file,
+with the go.sum file next to it, a directory containing both files or a git
+revision. The packages, by default ./..., are analyzed for both states with
+the modules of the local module cache only, without network access.
+
+Flags:
+`
+
+// modState is a state of the go.mod and the go.sum file of the main module.
+type modState struct {
+	name  string
+	goMod []byte
+	goSum []byte
+}
+
+// runDiff runs the diff command with args and returns the exit code.
+func runDiff(args []string) int {
+	flags := flag.NewFlagSet("diff", flag.ExitOnError)
+	cacheDir := flags.String("cachedir", "", "directory of the capabilities cache (default: depcaps in the user cache directory)")
+	noCache := flags.Bool("nocache", false, "disable the capabilities cache")
+	format := flags.String("format", "text", "output format: text or json")
+	flags.Usage = func() {
+		fmt.Fprint(flags.Output(), diffUsage)
+		flags.PrintDefaults()
+	}
+	_ = flags.Parse(args) // flags uses flag.ExitOnError
+
+	if flags.NArg() < 2 {
+		flags.Usage()
+		return 1
+	}
+	if *format != "text" && *format != "json" {
+		fmt.Fprintf(os.Stderr, "depcaps diff: unknown output format %q\n", *format)
+		return 1
+	}
+
+	patterns := flags.Args()[2:]
+	if len(patterns) == 0 {
+		patterns = []string{"./..."}
+	}
+
+	opts := []depcaps.Option{depcaps.WithCacheDir(*cacheDir)}
+	if *noCache {
+		opts = append(opts, depcaps.WithoutCache())
+	}
+
+	diffs, err := diff(context.Background(), flags.Arg(0), flags.Arg(1), patterns, opts)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "depcaps diff: %v\n", err)
+		return 1
+	}
+
+	if *format == "json" {
+		if diffs == nil {
+			diffs = []depcaps.ModuleDiff{}
+		}
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		err = enc.Encode(diffs)
+	} else {
+		err = depcaps.WriteModuleDiffs(os.Stdout, diffs)
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "depcaps diff: %v\n", err)
+		return 1
+	}
+
+	return 0
+}
+
+// diff analyzes the packages matching patterns for the states oldArg and
+// newArg of go.mod and go.sum and returns the changes of the modules.
+func diff(ctx context.Context, oldArg, newArg string, patterns []string, opts []depcaps.Option) ([]depcaps.ModuleDiff, error) {
+	goEnv, err := module.GetGoEnv(ctx, ".", nil, "GOMOD", "GOFLAGS")
+	if err != nil {
+		return nil, err
+	}
+	if goEnv["GOMOD"] == "" || goEnv["GOMOD"] == os.DevNull {
+		return nil, errors.New("working directory is not part of a module")
+	}
+	moduleDir := filepath.Dir(goEnv["GOMOD"])
+
+	oldState, err := readModState(ctx, moduleDir, oldArg)
+	if err != nil {
+		return nil, err
+	}
+	newState, err := readModState(ctx, moduleDir, newArg)
+	if err != nil {
+		return nil, err
+	}
+
+	oldFindings, oldVersions, err := analyzeModState(ctx, oldState, goEnv["GOFLAGS"], patterns, opts)
+	if err != nil {
+		return nil, err
+	}
+	newFindings, newVersions, err := analyzeModState(ctx, newState, goEnv["GOFLAGS"], patterns, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	return depcaps.DiffModules(oldFindings, newFindings, oldVersions, newVersions), nil
+}
+
+// readModState reads the state arg of go.mod and go.sum of the main module in
+// moduleDir. arg is either a go.mod file, a directory or a git revision.
+func readModState(ctx context.Context, moduleDir, arg string) (modState, error) {
+	if fi, err := os.Stat(arg); err == nil {
+		goModFile := arg
+		if fi.IsDir() {
+			goModFile = filepath.Join(arg, "go.mod")
+		}
+		goMod, err := os.ReadFile(goModFile)
+		if err != nil {
+			return modState{}, err
+		}
+		goSum, err := os.ReadFile(strings.TrimSuffix(goModFile, ".mod") + ".sum")
+		if err != nil && !errors.Is(err, os.ErrNotExist) {
+			return modState{}, err
+		}
+		return modState{name: arg, goMod: goMod, goSum: goSum}, nil
+	}
+
+	goMod, err := gitShow(ctx, moduleDir, arg, "go.mod")
+	if err != nil {
+		return modState{}, fmt.Errorf("%s is neither a file nor a git revision: %w", arg, err)
+	}
+	var goSum []byte
+	if gitExists(ctx, moduleDir, arg, "go.sum") {
+		goSum, err = gitShow(ctx, moduleDir, arg, "go.sum")
+		if err != nil {
+			return modState{}, err
+		}
+	}
+	return modState{name: arg, goMod: goMod, goSum: goSum}, nil
+}
+
+// gitShow returns the content of the file in dir at the git revision rev.
+func gitShow(ctx context.Context, dir, rev, file string) ([]byte, error) {
+	cmd := exec.CommandContext(ctx, "git", "show", rev+":./"+file)
+	cmd.Dir = dir
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+
+	out, err := cmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("command git show: %w: %s", err, strings.TrimSpace(stderr.String()))
+	}
+	return out, nil
+}
+
+// gitExists reports, if the file in dir exists at the git revision rev.
+func gitExists(ctx context.Context, dir, rev, file string) bool {
+	cmd := exec.CommandContext(ctx, "git", "cat-file", "-e", rev+":./"+file)
+	cmd.Dir = dir
+	return cmd.Run() == nil
+}
+
+// analyzeModState analyzes the packages matching patterns with the go.mod and
+// go.sum files of state. The modules are only loaded from the local module
+// cache. It returns the findings and the required versions of the modules.
+func analyzeModState(ctx context.Context, state modState, goflags string, patterns []string, opts []depcaps.Option) ([]depcaps.Finding, map[string]string, error) {
+	mf, err := modfile.Parse("go.mod", state.goMod, nil)
+	if err != nil {
+		return nil, nil, fmt.Errorf("%s: %w", state.name, err)
+	}
+	versions := make(map[string]string, len(mf.Require))
+	for _, r := range mf.Require {
+		versions[r.Mod.Path] = r.Mod.Version
+	}
+
+	dir, err := os.MkdirTemp("", "depcaps-diff-")
+	if err != nil {
+		return nil, nil, err
+	}
+	defer os.RemoveAll(dir)
+
+	// The go.sum file of an alternate go.mod file is the file next to it with
+	// the extension .sum.
+	goModFile := filepath.Join(dir, "go.mod")
+	err = os.WriteFile(goModFile, state.goMod, 0o600)
+	if err != nil {
+		return nil, nil, err
+	}
+	err = os.WriteFile(filepath.Join(dir, "go.sum"), state.goSum, 0o600)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	// The path of the temporary directory might contain spaces, so the flag is
+	// quoted.
+	modFileFlag, err := module.QuoteGoFlag("-modfile=" + goModFile)
+	if err != nil {
+		return nil, nil, err
+	}
+	env := []string{
+		"GOFLAGS=" + strings.TrimSpace(goflags+" -mod=readonly "+modFileFlag),
+		"GOPROXY=off",
+		"GOWORK=off",
+	}
+	d, err := depcaps.New(nil, append(opts[:len(opts):len(opts)], depcaps.WithEnv(env...))...)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	findings, err := d.Analyze(ctx, ".", patterns...)
+	if err != nil {
+		return nil, nil, fmt.Errorf("%s: %w", state.name, err)
+	}
+
+	return findings, versions, nil
+}
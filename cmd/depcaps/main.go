@@ -1,11 +1,15 @@
 package main
 
 import (
+	"context"
+	"errors"
+	"flag"
 	"fmt"
 	"os"
 	"path/filepath"
 	"runtime"
 	"runtime/debug"
+	"strings"
 
 	"golang.org/x/tools/go/analysis/singlechecker"
 
@@ -18,14 +22,198 @@ var (
 	date    = ""
 )
 
+var (
+	format          string
+	graphPackage    string
+	graphCapability string
+	failSeverity    string
+	perModule       bool
+	fix             bool
+)
+
 func main() {
 	depcaps.Version = buildVersion()
 
-	analyzer := depcaps.New(nil).WithFlagArgs().AsAnalyzer(true)
+	if len(os.Args) > 1 {
+		switch os.Args[1] {
+		case "diff":
+			os.Exit(runDiff(os.Args[2:]))
+		case "audit":
+			os.Exit(runAudit(os.Args[2:]))
+		}
+	}
+
+	d, err := depcaps.New(nil)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "depcaps: %v\n", err)
+		os.Exit(1)
+	}
+	analyzer := d.AsAnalyzer(true)
+
+	// The flags of the own driver, which merges the findings of all the
+	// packages.
+	analyzer.Flags.StringVar(&format, "format", "text", "output format: text, sarif, json, markdown, html, dot or mermaid")
+	analyzer.Flags.StringVar(&graphPackage, "graphpackage", "", "limit the graph output to the findings of this package, either dependency or own package")
+	analyzer.Flags.StringVar(&graphCapability, "graphcapability", "", "limit the graph output to the findings of this capability")
+	analyzer.Flags.StringVar(&failSeverity, "failseverity", "error", "lowest severity of violations, which fails with exit code 3: error, warning or info")
+	analyzer.Flags.BoolVar(&perModule, "permodule", false, "report every not allowed capability of a module once at its require directive in go.mod")
+
+	if ownDriver(&analyzer.Flags, os.Args[1:]) {
+		// -fix is a flag of the analysis driver as well, which applies the
+		// suggested fixes of every package on its own. The own driver merges
+		// them.
+		analyzer.Flags.BoolVar(&fix, "fix", false, "apply all suggested fixes")
+		os.Exit(report(d, &analyzer.Flags, os.Args[1:]))
+	}
 
 	singlechecker.Main(analyzer)
 }
 
+// ownDriverFlags are the flags, which request the own driver.
+var ownDriverFlags = map[string]bool{
+	"format":          true,
+	"graphpackage":    true,
+	"graphcapability": true,
+	"failseverity":    true,
+	"permodule":       true,
+	"timeout":         true,
+}
+
+// ownDriver returns true, if an output format, the exit code according to
+// the severity, the findings per module or a timeout with partial results
+// are requested in args. Otherwise, the findings are reported as diagnostics
+// by the analysis driver, which can neither return structured results,
+// partial results nor results merged across packages.
+func ownDriver(flags *flag.FlagSet, args []string) bool {
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		if arg == "--" || !strings.HasPrefix(arg, "-") {
+			return false
+		}
+
+		name, _, hasValue := strings.Cut(strings.TrimLeft(arg, "-"), "=")
+		if ownDriverFlags[name] {
+			return true
+		}
+
+		// Skip the value of flags given in the form -name value.
+		f := flags.Lookup(name)
+		if f == nil || hasValue {
+			continue
+		}
+		if b, ok := f.Value.(interface{ IsBoolFlag() bool }); ok && b.IsBoolFlag() {
+			continue
+		}
+		i++
+	}
+
+	return false
+}
+
+// report analyzes the packages given in args and writes the findings in the
+// requested format to stdout. With -fix, the not allowed capabilities are
+// added to the config file. It returns the exit code, which is 3, if the
+// highest severity of the violations is at least -failseverity. If the
+// analysis stops because of -timeout, the findings of the packages analyzed
+// so far are written, no fixes are applied and the exit code is 1.
+func report(d *depcaps.Linter, flags *flag.FlagSet, args []string) int {
+	_ = flags.Parse(args) // flags uses flag.ExitOnError
+
+	err := depcaps.WithPerModule(perModule)(d)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "depcaps: %v\n", err)
+		return 1
+	}
+
+	threshold, err := depcaps.ParseSeverity(failSeverity)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "depcaps: -failseverity: %v\n", err)
+		return 1
+	}
+
+	findings, analyzeErr := d.Analyze(context.Background(), ".", flags.Args()...)
+	if analyzeErr != nil && !errors.Is(analyzeErr, context.DeadlineExceeded) {
+		fmt.Fprintf(os.Stderr, "depcaps: %v\n", analyzeErr)
+		return 1
+	}
+
+	err = writeReport(format, findings)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "depcaps: %v\n", err)
+		return 1
+	}
+
+	if fix && analyzeErr == nil {
+		err = d.Fix(findings)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "depcaps: %v\n", err)
+			return 1
+		}
+	}
+
+	if analyzeErr != nil {
+		fmt.Fprintf(os.Stderr, "depcaps: %v, the results are partial\n", analyzeErr)
+		return 1
+	}
+
+	if depcaps.HighestSeverity(findings) >= threshold {
+		return 3
+	}
+	return 0
+}
+
+func writeReport(format string, findings []depcaps.Finding) error {
+	switch format {
+	case "text":
+		return depcaps.WriteText(os.Stdout, findings)
+	case "sarif":
+		return depcaps.WriteSARIF(os.Stdout, ".", findings)
+	case "dot":
+		return depcaps.NewGraph(graphFindings(findings)).WriteDOT(os.Stdout)
+	case "mermaid":
+		return depcaps.NewGraph(graphFindings(findings)).WriteMermaid(os.Stdout)
+	}
+
+	r, err := depcaps.NewReport(".", findings)
+	if err != nil {
+		return err
+	}
+
+	switch format {
+	case "json":
+		return r.WriteJSON(os.Stdout)
+	case "markdown":
+		return r.WriteMarkdown(os.Stdout)
+	case "html":
+		return r.WriteHTML(os.Stdout)
+	default:
+		return fmt.Errorf("unknown output format %q", format)
+	}
+}
+
+// graphFindings returns the findings selected for the graph output by
+// -graphpackage and -graphcapability.
+func graphFindings(findings []depcaps.Finding) []depcaps.Finding {
+	var selected []depcaps.Finding
+	for _, f := range findings {
+		// Findings merged per module are selected by their affected packages.
+		candidates := f.Affected
+		if len(candidates) == 0 {
+			candidates = []depcaps.Finding{f}
+		}
+		for _, c := range candidates {
+			if graphPackage != "" && c.Dependency != graphPackage && c.Package != graphPackage {
+				continue
+			}
+			if graphCapability != "" && c.Capability.String() != graphCapability {
+				continue
+			}
+			selected = append(selected, c)
+		}
+	}
+	return selected
+}
+
 func buildVersion() string {
 	result := fmt.Sprintf("%s version %s", filepath.Base(os.Args[0]), version)
 
@@ -0,0 +1,108 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestMain(m *testing.M) {
+	// The test binary runs the command, if requested by runCommand.
+	if os.Getenv("DEPCAPS_TEST_MAIN") == "1" {
+		main()
+		os.Exit(0)
+	}
+
+	os.Exit(m.Run())
+}
+
+func TestCommand(t *testing.T) {
+	warningConfig := filepath.Join(t.TempDir(), "warning.json")
+	err := os.WriteFile(warningConfig, []byte(`{"GlobalCapabilitySeverity": {"CAPABILITY_FILES": "warning", "CAPABILITY_NETWORK": "warning", "CAPABILITY_REFLECT": "warning"}}`), 0o600)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	const finding = "simple/function/func_go122.go:7:2: %sPackage github.com/google/uuid has not allowed capability CAPABILITY_REFLECT"
+
+	tt := []struct {
+		name string
+		args []string
+
+		wantCode   int
+		wantStdout string
+		wantStderr string
+	}{
+		{
+			// By default, the analysis driver reports the diagnostics and
+			// fails for every finding.
+			name:       "default",
+			args:       []string{"./simple/function"},
+			wantCode:   3,
+			wantStderr: strings.Replace(finding, "%s", "", 1),
+		},
+		{
+			name:       "flag of the analysis driver",
+			args:       []string{"-test=false", "./simple/function"},
+			wantCode:   3,
+			wantStderr: strings.Replace(finding, "%s", "", 1),
+		},
+		{
+			name:       "default with warnings",
+			args:       []string{"-config", warningConfig, "./simple/function"},
+			wantCode:   3,
+			wantStderr: strings.Replace(finding, "%s", "warning: ", 1),
+		},
+		{
+			name:       "format",
+			args:       []string{"-format", "text", "./simple/function"},
+			wantCode:   3,
+			wantStdout: strings.Replace(finding, "%s", "error: ", 1),
+		},
+		{
+			name:       "failseverity",
+			args:       []string{"-config", warningConfig, "-failseverity", "error", "./simple/function"},
+			wantStdout: strings.Replace(finding, "%s", "warning: ", 1),
+		},
+	}
+
+	cacheDir := t.TempDir()
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			code, stdout, stderr := runCommand(t, append([]string{"-cachedir", cacheDir}, tc.args...))
+			if code != tc.wantCode {
+				t.Fatalf("expected exit code %d, got %d, stderr:\n%s", tc.wantCode, code, stderr)
+			}
+			if tc.wantStdout != "" && !strings.Contains(stdout, tc.wantStdout) {
+				t.Fatalf("expected stdout to contain %q, got:\n%s", tc.wantStdout, stdout)
+			}
+			if tc.wantStderr != "" && !strings.Contains(stderr, tc.wantStderr) {
+				t.Fatalf("expected stderr to contain %q, got:\n%s", tc.wantStderr, stderr)
+			}
+		})
+	}
+}
+
+// runCommand runs the command with args in the testdata module alltest and
+// returns the exit code, stdout and stderr.
+func runCommand(t *testing.T, args []string) (int, string, string) {
+	t.Helper()
+
+	cmd := exec.Command(os.Args[0], args...)
+	cmd.Dir = filepath.Join("..", "..", "testdata", "src", "alltest")
+	cmd.Env = append(os.Environ(), "DEPCAPS_TEST_MAIN=1")
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+
+	err := cmd.Run()
+	var exitErr *exec.ExitError
+	if err != nil && !errors.As(err, &exitErr) {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	return cmd.ProcessState.ExitCode(), stdout.String(), stderr.String()
+}
@@ -3,6 +3,7 @@ module github.com/breml/depcaps
 go 1.23.3
 
 require (
+	github.com/golangci/plugin-module-register v0.1.1
 	golang.org/x/tools v0.27.0
 	google.golang.org/protobuf v1.35.2
 )
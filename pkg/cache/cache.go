@@ -0,0 +1,199 @@
+// Package cache provides an on-disk cache for the capabilities of
+// dependency modules and for the set of packages of the standard library.
+//
+// The capabilities of a module at a given version do not change, as long as
+// the module content (go.sum hash), the imported packages, the Go version, the
+// build configuration and the classifier used for the analysis stay the same.
+// Therefore the results of the capability analysis can be reused between
+// runs.
+package cache
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// formatVersion is part of every cache key and needs to be increased, if the
+// content or the format of the cache entries changes.
+const formatVersion = "7"
+
+// Key identifies the cached capabilities of a single package of a module.
+type Key struct {
+	// Module is the module path.
+	Module string
+	// Version is the module version.
+	Version string
+	// Sum is the hash of the module from go.sum.
+	Sum string
+	// Package is the package path.
+	Package string
+	// Imports identifies the imported packages, e.g. by the IDs of their
+	// keys, since the capabilities of a package depend on the capabilities
+	// of the imported packages, whose versions are selected by the main
+	// module.
+	Imports string
+	// GoVersion is the version of the Go toolchain used for the analysis.
+	GoVersion string
+	// BuildConfig describes the build configuration, e.g. GOOS, GOARCH and
+	// build tags.
+	BuildConfig string
+	// Classifier identifies the classifier used to assign capabilities.
+	Classifier string
+}
+
+// Valid reports, if the key is complete and therefore usable for caching.
+// Modules without version or go.sum hash, e.g. modules replaced with a local
+// directory, can not be cached.
+func (k Key) Valid() bool {
+	return k.Module != "" && k.Version != "" && k.Sum != "" && k.Package != ""
+}
+
+// ID returns the identifier of the key, which is a hash of all its fields.
+func (k Key) ID() string {
+	h := sha256.New()
+	for _, s := range []string{formatVersion, k.Module, k.Version, k.Sum, k.Package, k.Imports, k.GoVersion, k.BuildConfig, k.Classifier} {
+		fmt.Fprintf(h, "%q\n", s)
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// StdKey identifies the cached set of packages of the standard library.
+type StdKey struct {
+	// GOROOT is the root of the Go tree.
+	GOROOT string
+	// GoVersion is the version of the Go toolchain.
+	GoVersion string
+	// GOOS is the target operating system.
+	GOOS string
+	// GOARCH is the target architecture.
+	GOARCH string
+	// BuildFlags are the build flags, e.g. the build tags.
+	BuildFlags string
+}
+
+func (k StdKey) hash() string {
+	h := sha256.New()
+	for _, s := range []string{formatVersion, k.GOROOT, k.GoVersion, k.GOOS, k.GOARCH, k.BuildFlags} {
+		fmt.Fprintf(h, "%q\n", s)
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// Cache is an on-disk cache for the capabilities of modules and for the set
+// of packages of the standard library.
+type Cache struct {
+	dir string
+}
+
+// New returns a cache located in dir. If dir is empty, the cache is located
+// in the depcaps directory in the user cache directory.
+func New(dir string) (*Cache, error) {
+	if dir == "" {
+		userCacheDir, err := os.UserCacheDir()
+		if err != nil {
+			return nil, fmt.Errorf("locating user cache dir: %w", err)
+		}
+		dir = filepath.Join(userCacheDir, "depcaps")
+	}
+
+	return &Cache{
+		dir: dir,
+	}, nil
+}
+
+// Get returns the cached capabilities for the package identified by key. The
+// second return value reports, if an entry has been found.
+func (c *Cache) Get(key Key) ([]byte, bool) {
+	if !key.Valid() {
+		return nil, false
+	}
+
+	data, err := os.ReadFile(c.packagePath(key))
+	if err != nil {
+		return nil, false
+	}
+
+	return data, true
+}
+
+// Put stores the capabilities for the package identified by key. The
+// capabilities are stored in the encoded form provided by the caller.
+func (c *Cache) Put(key Key, data []byte) error {
+	if !key.Valid() {
+		return errors.New("incomplete cache key for package " + key.Package)
+	}
+
+	return c.write(c.packagePath(key), data)
+}
+
+// GetStd returns the cached package paths of the standard library identified
+// by key. The second return value reports, if an entry has been found.
+func (c *Cache) GetStd(key StdKey) ([]string, bool) {
+	data, err := os.ReadFile(c.stdPath(key))
+	if err != nil {
+		return nil, false
+	}
+
+	var pkgPaths []string
+	err = json.Unmarshal(data, &pkgPaths)
+	if err != nil {
+		return nil, false
+	}
+
+	return pkgPaths, true
+}
+
+// PutStd stores the package paths of the standard library identified by key.
+func (c *Cache) PutStd(key StdKey, pkgPaths []string) error {
+	data, err := json.Marshal(pkgPaths)
+	if err != nil {
+		return fmt.Errorf("marshaling standard library cache entry: %w", err)
+	}
+
+	return c.write(c.stdPath(key), data)
+}
+
+func (c *Cache) stdPath(key StdKey) string {
+	return filepath.Join(c.dir, "std", key.hash()+".json")
+}
+
+func (c *Cache) packagePath(key Key) string {
+	h := key.ID()
+	return filepath.Join(c.dir, "modules", h[:2], h+".bin")
+}
+
+// write writes data to filename in an atomic way, such that concurrent runs
+// never observe partially written cache entries.
+func (c *Cache) write(filename string, data []byte) error {
+	err := os.MkdirAll(filepath.Dir(filename), 0o755)
+	if err != nil {
+		return fmt.Errorf("creating cache dir: %w", err)
+	}
+
+	f, err := os.CreateTemp(filepath.Dir(filename), strings.TrimSuffix(filepath.Base(filename), filepath.Ext(filename))+".*.tmp")
+	if err != nil {
+		return fmt.Errorf("creating cache entry: %w", err)
+	}
+	defer func() {
+		_ = os.Remove(f.Name())
+	}()
+
+	_, err = f.Write(data)
+	if err != nil {
+		_ = f.Close()
+		return fmt.Errorf("writing cache entry: %w", err)
+	}
+
+	err = f.Close()
+	if err != nil {
+		return fmt.Errorf("writing cache entry: %w", err)
+	}
+
+	return os.Rename(f.Name(), filename)
+}
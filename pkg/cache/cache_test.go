@@ -0,0 +1,121 @@
+package cache_test
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+
+	"github.com/breml/depcaps/pkg/cache"
+)
+
+func TestCache(t *testing.T) {
+	c, err := cache.New(t.TempDir())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	key := cache.Key{
+		Module:      "github.com/google/uuid",
+		Version:     "v1.3.1",
+		Sum:         "h1:KjJaJ9iWZ3jOFZIf1Lqf4laDRCasjl0BCmnEGxkdLb4=",
+		Package:     "github.com/google/uuid",
+		GoVersion:   "go1.23.3",
+		BuildConfig: "linux/amd64",
+		Classifier:  "default",
+	}
+
+	_, ok := c.Get(key)
+	if ok {
+		t.Fatalf("expected cache miss on empty cache")
+	}
+
+	data := []byte("capabilities of github.com/google/uuid")
+
+	err = c.Put(key, data)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	got, ok := c.Get(key)
+	if !ok {
+		t.Fatalf("expected cache hit")
+	}
+	if !bytes.Equal(data, got) {
+		t.Fatalf("expected %q, got: %q", data, got)
+	}
+
+	otherKey := key
+	otherKey.GoVersion = "go1.22.0"
+	_, ok = c.Get(otherKey)
+	if ok {
+		t.Fatalf("expected cache miss for different Go version")
+	}
+
+	otherKey = key
+	otherKey.Imports = "other imports"
+	_, ok = c.Get(otherKey)
+	if ok {
+		t.Fatalf("expected cache miss for different imports")
+	}
+}
+
+func TestCacheInvalidKey(t *testing.T) {
+	c, err := cache.New(t.TempDir())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	key := cache.Key{
+		Module: "example.com/replaced",
+	}
+
+	err = c.Put(key, []byte("capabilities"))
+	if err == nil {
+		t.Fatalf("expected error for incomplete key")
+	}
+
+	_, ok := c.Get(key)
+	if ok {
+		t.Fatalf("expected cache miss for incomplete key")
+	}
+}
+
+func TestCacheStd(t *testing.T) {
+	c, err := cache.New(t.TempDir())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	key := cache.StdKey{
+		GOROOT:    "/usr/local/go",
+		GoVersion: "go1.23.3",
+		GOOS:      "linux",
+		GOARCH:    "amd64",
+	}
+
+	_, ok := c.GetStd(key)
+	if ok {
+		t.Fatalf("expected cache miss on empty cache")
+	}
+
+	pkgPaths := []string{"fmt", "os"}
+	err = c.PutStd(key, pkgPaths)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	got, ok := c.GetStd(key)
+	if !ok {
+		t.Fatalf("expected cache hit")
+	}
+	if !reflect.DeepEqual(pkgPaths, got) {
+		t.Fatalf("expected %v, got: %v", pkgPaths, got)
+	}
+
+	otherKey := key
+	otherKey.GOOS = "windows"
+	_, ok = c.GetStd(otherKey)
+	if ok {
+		t.Fatalf("expected cache miss for different GOOS")
+	}
+}
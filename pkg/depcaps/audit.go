@@ -0,0 +1,322 @@
+package depcaps
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"go/token"
+	"go/types"
+	"go/version"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	xmodule "golang.org/x/mod/module"
+	"golang.org/x/tools/go/packages"
+
+	"github.com/breml/depcaps/pkg/module"
+)
+
+// auditModulePath is the path of the temporary main module of an audit. The
+// top-level domain invalid is reserved, so the path never clashes with the
+// audited module.
+const auditModulePath = "depcaps.invalid/audit"
+
+// maxSamplePaths is the maximum number of call paths written per capability
+// by WriteAudit.
+const maxSamplePaths = 3
+
+// Audit analyzes the module modVersion, e.g. github.com/google/uuid@v1.3.1,
+// before it is added to the dependencies. The module is loaded from the local
+// module cache or from the GOPROXY given with WithEnv, e.g.
+// GOPROXY=file:///path/to/proxy, without network access.
+//
+// The module is required by a temporary main module, which references every
+// exported function and method of the packages of the module, except the
+// internal and the main packages. Generic functions and methods of generic
+// types are omitted. The findings hold the capabilities of the packages of
+// the module itself: the package and the dependency of a finding are the
+// package of the module, the position is the declaration of the exported
+// function, if any, and the call paths start with the exported functions.
+//
+// The settings are applied like in Check, except RequireExplicitApproval. The
+// settings might be nil.
+func Audit(ctx context.Context, modVersion string, settings *LinterSettings, opts ...Option) (*Report, error) {
+	path, vers, ok := strings.Cut(modVersion, "@")
+	if !ok {
+		return nil, fmt.Errorf("invalid module %q, expected path@version", modVersion)
+	}
+	err := xmodule.Check(path, vers)
+	if err != nil {
+		return nil, err
+	}
+
+	dir, err := os.MkdirTemp("", "depcaps-audit-")
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(dir)
+
+	// The requirements of the temporary main module are completed from the
+	// module cache or the proxy, only.
+	env := []string{"GOFLAGS=-mod=mod", "GOPROXY=off", "GOWORK=off"}
+	l, err := New(settings, append([]Option{WithDir(dir), WithEnv(env...)}, opts...)...)
+	if err != nil {
+		return nil, err
+	}
+	// The audited module is not a dependency yet, so it is not approved.
+	l.RequireExplicitApproval = false
+
+	err = writeAuditModule(ctx, dir, l.env, path, vers)
+	if err != nil {
+		return nil, err
+	}
+
+	pkgs, err := packages.Load(&packages.Config{
+		Context: ctx,
+		Mode:    packages.NeedName | packages.NeedTypes | packages.NeedModule,
+		Dir:     dir,
+		Env:     l.environ(),
+	}, path+"/...")
+	if err != nil {
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		return nil, err
+	}
+
+	src, decls, err := auditSource(path, pkgs)
+	if err != nil {
+		return nil, err
+	}
+	err = os.WriteFile(filepath.Join(dir, "audit.go"), src, 0o600)
+	if err != nil {
+		return nil, err
+	}
+
+	findings, err := l.Analyze(ctx, dir, ".")
+	if err != nil && !isContextError(err) {
+		return nil, err
+	}
+
+	for i := range findings {
+		if findings[i].Problem != "" {
+			continue
+		}
+		auditFinding(&findings[i], decls)
+	}
+	sortFindings(findings)
+
+	report, reportErr := NewReport(dir, findings)
+	if reportErr != nil {
+		return nil, reportErr
+	}
+	return report, err
+}
+
+// writeAuditModule writes the go.mod file of the temporary main module in
+// dir, which requires the module path at version vers.
+func writeAuditModule(ctx context.Context, dir string, env []string, path, vers string) error {
+	goEnv, err := module.GetGoEnv(ctx, dir, env, "GOVERSION")
+	if err != nil {
+		return err
+	}
+
+	var goMod bytes.Buffer
+	fmt.Fprintf(&goMod, "module %s\n\n", auditModulePath)
+	// The language version of a development version of Go is unknown.
+	if lang := version.Lang(goEnv["GOVERSION"]); lang != "" {
+		fmt.Fprintf(&goMod, "go %s\n\n", strings.TrimPrefix(lang, "go"))
+	}
+	fmt.Fprintf(&goMod, "require %s %s\n", path, vers)
+
+	return os.WriteFile(filepath.Join(dir, "go.mod"), goMod.Bytes(), 0o600)
+}
+
+// auditSource returns the source of the package of the temporary main
+// module, which references every exported function and method of pkgs in a
+// function of its own, such that the capabilities are reached through every
+// one of them. The positions of the declarations are returned by the names
+// of the functions.
+func auditSource(path string, pkgs []*packages.Package) ([]byte, map[string]token.Position, error) {
+	var imports, funcs bytes.Buffer
+	decls := make(map[string]token.Position)
+	var errs []error
+	n := 0
+	for i, pkg := range pkgs {
+		if len(pkg.Errors) > 0 {
+			for _, err := range pkg.Errors {
+				errs = append(errs, err)
+			}
+			continue
+		}
+		if pkg.Module == nil || pkg.Module.Path != path || pkg.Name == "main" || isInternal(pkg.PkgPath) {
+			continue
+		}
+
+		alias := fmt.Sprintf("p%d", i)
+		fmt.Fprintf(&imports, "\t%s %q\n", alias, pkg.PkgPath)
+
+		ref := func(expr, name string, obj types.Object) {
+			fmt.Fprintf(&funcs, "\nfunc f%d() { use(%s) }\n", n, expr)
+			decls[name] = pkg.Fset.Position(obj.Pos())
+			n++
+		}
+
+		scope := pkg.Types.Scope()
+		for _, name := range scope.Names() {
+			switch obj := scope.Lookup(name).(type) {
+			case *types.Func:
+				if !obj.Exported() || obj.Type().(*types.Signature).TypeParams().Len() > 0 {
+					continue
+				}
+				ref(alias+"."+name, obj.FullName(), obj)
+
+			case *types.TypeName:
+				named, ok := obj.Type().(*types.Named)
+				if !ok || !obj.Exported() || obj.IsAlias() || named.TypeParams().Len() > 0 || types.IsInterface(named) {
+					continue
+				}
+				for j := 0; j < named.NumMethods(); j++ {
+					m := named.Method(j)
+					if !m.Exported() {
+						continue
+					}
+					if _, ptr := m.Type().(*types.Signature).Recv().Type().(*types.Pointer); ptr {
+						ref(fmt.Sprintf("(*%s.%s).%s", alias, name, m.Name()), fmt.Sprintf("(*%s.%s).%s", pkg.PkgPath, name, m.Name()), m)
+						continue
+					}
+					ref(fmt.Sprintf("%s.%s.%s", alias, name, m.Name()), fmt.Sprintf("(%s.%s).%s", pkg.PkgPath, name, m.Name()), m)
+				}
+			}
+		}
+	}
+
+	if imports.Len() == 0 {
+		if len(errs) > 0 {
+			return nil, nil, errors.Join(errs...)
+		}
+		return nil, nil, fmt.Errorf("module %s has no packages to audit", path)
+	}
+
+	var src bytes.Buffer
+	src.WriteString("// Code generated by depcaps audit. DO NOT EDIT.\n\n")
+	src.WriteString("package audit\n\n")
+	fmt.Fprintf(&src, "import (\n%s)\n\n", imports.String())
+	src.WriteString("func use(interface{}) {}\n")
+	src.Write(funcs.Bytes())
+
+	return src.Bytes(), decls, nil
+}
+
+// auditFinding turns the finding f of the temporary main module into the
+// finding of the package of the audited module, which starts with the
+// referenced exported function.
+func auditFinding(f *Finding, decls map[string]token.Position) {
+	f.Package = f.Dependency
+	// Capabilities reached through the initialization of the package have no
+	// declaration, the position in the temporary main module is meaningless.
+	f.Position = token.Position{}
+	if len(f.Path) > 1 {
+		f.Position = decls[f.Path[1].Function]
+	}
+	f.Path = auditPath(f.Path)
+	for i := range f.Paths {
+		f.Paths[i] = auditPath(f.Paths[i])
+	}
+}
+
+// auditPath returns path without the function of the temporary main module.
+// The call site of the referenced function is in the temporary main module,
+// so it is omitted as well.
+func auditPath(path []CallSite) []CallSite {
+	if len(path) < 2 {
+		return path
+	}
+	path = path[1:]
+	path[0].Position = token.Position{}
+	return path
+}
+
+func isInternal(pkgPath string) bool {
+	for _, elem := range strings.Split(pkgPath, "/") {
+		if elem == "internal" {
+			return true
+		}
+	}
+	return false
+}
+
+// WriteAudit writes the capabilities of the findings of Audit to w grouped by
+// module and package. Every capability is followed by the decision and up to
+// three sample call paths. The problems of the analysis are written first.
+func WriteAudit(w io.Writer, findings []Finding) error {
+	var sorted []Finding
+	for _, f := range findings {
+		if f.Problem == "" {
+			sorted = append(sorted, f)
+			continue
+		}
+		_, err := fmt.Fprintf(w, "%s: %s\n", f.Severity, f.Problem)
+		if err != nil {
+			return err
+		}
+	}
+	sort.SliceStable(sorted, func(i, j int) bool {
+		a, b := sorted[i], sorted[j]
+		if a.Module != b.Module {
+			return a.Module < b.Module
+		}
+		if a.Dependency != b.Dependency {
+			return a.Dependency < b.Dependency
+		}
+		return a.Capability.String() < b.Capability.String()
+	})
+
+	current := ""
+	for i, f := range sorted {
+		if i == 0 || f.Module != current {
+			current = f.Module
+			_, err := fmt.Fprintf(w, "%s %s\n", f.Module, f.Version)
+			if err != nil {
+				return err
+			}
+		}
+
+		decision := decisionText(f.Decision, f.Dependency)
+		if f.Decision == Violation {
+			decision = fmt.Sprintf("%s (%s)", decision, f.Severity)
+		}
+		_, err := fmt.Fprintf(w, "\t%s: %s: %s\n", f.Dependency, f.Capability, decision)
+		if err != nil {
+			return err
+		}
+
+		paths := f.Paths
+		if len(paths) == 0 && len(f.Path) > 0 {
+			paths = [][]CallSite{f.Path}
+		}
+		for j, path := range paths {
+			if j == maxSamplePaths {
+				_, err = fmt.Fprintf(w, "\t\t... %d more\n", len(paths)-maxSamplePaths)
+				if err != nil {
+					return err
+				}
+				break
+			}
+			names := make([]string, 0, len(path))
+			for _, cs := range path {
+				names = append(names, cs.Function)
+			}
+			_, err = fmt.Fprintf(w, "\t\t%s\n", strings.Join(names, " -> "))
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,109 @@
+package depcaps_test
+
+import (
+	"bytes"
+	"context"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/breml/depcaps/pkg/depcaps"
+)
+
+func TestAudit(t *testing.T) {
+	proxy := t.TempDir()
+	writeProxyModule(t, proxy, "example.com/audited", "v1.0.0", map[string]string{
+		"go.mod":     "module example.com/audited\n\ngo 1.21\n",
+		"audited.go": "package audited\n\nimport (\n\t\"net\"\n\t\"os\"\n)\n\nfunc Dial() { _, _ = net.Dial(\"tcp\", \"localhost:0\") }\n\nfunc Group() int { return os.Getgid() }\n",
+	})
+
+	settings := &depcaps.LinterSettings{
+		PackageAllowedCapabilities: map[string]map[string]bool{
+			"example.com/audited": {"CAPABILITY_NETWORK": true},
+		},
+	}
+
+	// The module is loaded from the local proxy, so the test does not depend
+	// on the module cache.
+	report, err := depcaps.Audit(context.Background(), "example.com/audited@v1.0.0", settings,
+		depcaps.WithCacheDir(t.TempDir()),
+		depcaps.WithEnv(
+			"GOPROXY=file://"+filepath.ToSlash(proxy),
+			"GOMODCACHE="+t.TempDir(),
+			"GOFLAGS=-mod=mod -modcacherw",
+			"GONOSUMDB=example.com",
+			"GOSUMDB=off",
+		),
+	)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	decisions := make(map[string]depcaps.Decision)
+	for _, f := range report.Findings {
+		if f.Package != "example.com/audited" || f.Dependency != "example.com/audited" {
+			t.Fatalf("expected finding of package example.com/audited, got: %s reaching %s", f.Package, f.Dependency)
+		}
+		if f.Module != "example.com/audited" || f.Version != "v1.0.0" {
+			t.Fatalf("expected module example.com/audited@v1.0.0, got: %s@%s", f.Module, f.Version)
+		}
+		if len(f.Path) == 0 || f.Path[0].Package != "example.com/audited" {
+			t.Fatalf("expected call path starting with an exported function of example.com/audited, got: %+v", f.Path)
+		}
+		if f.Position.IsValid() && !strings.Contains(f.Position.Filename, "example.com/audited@v1.0.0") {
+			t.Fatalf("expected position in example.com/audited@v1.0.0, got: %s", f.Position)
+		}
+		decisions[f.Capability.String()] = f.Decision
+	}
+
+	if d, ok := decisions["CAPABILITY_NETWORK"]; !ok || d != depcaps.AllowedPackage {
+		t.Fatalf("expected CAPABILITY_NETWORK allowed by package, got: %v", decisions)
+	}
+	if d, ok := decisions["CAPABILITY_READ_SYSTEM_STATE"]; !ok || d != depcaps.Violation {
+		t.Fatalf("expected CAPABILITY_READ_SYSTEM_STATE as violation, got: %v", decisions)
+	}
+
+	var buf bytes.Buffer
+	err = depcaps.WriteAudit(&buf, report.Findings)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	for _, want := range []string{
+		"example.com/audited v1.0.0\n",
+		"\texample.com/audited: CAPABILITY_NETWORK: allowed by PackageAllowedCapabilities of example.com/audited\n",
+		"\texample.com/audited: CAPABILITY_READ_SYSTEM_STATE: violation (error)\n",
+		"\t\texample.com/audited.Group -> os.Getgid\n",
+	} {
+		if !strings.Contains(buf.String(), want) {
+			t.Fatalf("expected audit to contain %q, got:\n%s", want, buf.String())
+		}
+	}
+}
+
+func TestAuditError(t *testing.T) {
+	tt := []struct {
+		name       string
+		modVersion string
+		wantErr    string
+	}{
+		{
+			name:       "missing version",
+			modVersion: "github.com/google/uuid",
+			wantErr:    `invalid module "github.com/google/uuid", expected path@version`,
+		},
+		{
+			name:       "invalid version",
+			modVersion: "github.com/google/uuid@latest",
+			wantErr:    "invalid version",
+		},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := depcaps.Audit(context.Background(), tc.modVersion, nil, depcaps.WithoutCache())
+			if err == nil || !strings.Contains(err.Error(), tc.wantErr) {
+				t.Fatalf("expected error containing %q, got: %v", tc.wantErr, err)
+			}
+		})
+	}
+}
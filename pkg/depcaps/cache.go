@@ -0,0 +1,160 @@
+package depcaps
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"go/types"
+	"runtime/debug"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/google/capslock/analyzer"
+	"golang.org/x/tools/go/analysis"
+	"golang.org/x/tools/go/packages"
+
+	"github.com/breml/depcaps/pkg/cache"
+	"github.com/breml/depcaps/pkg/module"
+)
+
+// cacheKey returns the cache key for the package analyzed by pass. Only
+// packages of dependency modules, identified by their version, are cached,
+// if all the imported packages are cacheable as well. The second return value
+// reports, if the package is cacheable.
+func (d *Linter) cacheKey(pass *analysis.Pass) (cache.Key, bool) {
+	if d.cache == nil || pass.Module == nil || pass.Module.Version == "" {
+		return cache.Key{}, false
+	}
+
+	imports, ok := d.importsID(pass)
+	if !ok {
+		return cache.Key{}, false
+	}
+
+	env := d.goEnv
+	key := cache.Key{
+		Module:      pass.Module.Path,
+		Version:     pass.Module.Version,
+		Sum:         d.sums[pass.Module.Path+"@"+pass.Module.Version],
+		Package:     pass.Pkg.Path(),
+		Imports:     imports,
+		GoVersion:   env["GOVERSION"],
+		BuildConfig: fmt.Sprintf("GOOS=%s GOARCH=%s CGO_ENABLED=%s GOFLAGS=%s", env["GOOS"], env["GOARCH"], env["CGO_ENABLED"], buildFlags(env["GOFLAGS"])),
+		Classifier:  classifierID(),
+	}
+
+	return key, key.Valid()
+}
+
+// importsID returns the hash of the IDs of the cache keys of the packages
+// imported by the package analyzed by pass. The IDs of the imported packages
+// cover their imports in turn, the packages of the standard library are
+// covered by the Go version of the cache key. If an imported package is not
+// cacheable, e.g. the package of a replaced module, false is returned.
+func (d *Linter) importsID(pass *analysis.Pass) (string, bool) {
+	imports := append([]*types.Package(nil), pass.Pkg.Imports()...)
+	sort.Slice(imports, func(i, j int) bool {
+		return imports[i].Path() < imports[j].Path()
+	})
+
+	h := sha256.New()
+	for _, imp := range imports {
+		if _, ok := d.stdSet[imp.Path()]; ok {
+			continue
+		}
+
+		fact := &packageCapabilities{}
+		if !pass.ImportPackageFact(imp, fact) || fact.keyID == "" {
+			return "", false
+		}
+		fmt.Fprintf(h, "%q %q\n", imp.Path(), fact.keyID)
+	}
+	return hex.EncodeToString(h.Sum(nil)), true
+}
+
+// buildFlags returns the flags of goflags, which might change the build of a
+// module version. An alternate go.mod file of the main module does not.
+func buildFlags(goflags string) string {
+	all, err := module.SplitGoFlags(goflags)
+	if err != nil {
+		// The go command fails for a malformed GOFLAGS anyway.
+		all = strings.Fields(goflags)
+	}
+
+	var flags []string
+	for _, flag := range all {
+		if strings.HasPrefix(flag, "-modfile=") || strings.HasPrefix(flag, "--modfile=") {
+			continue
+		}
+		flags = append(flags, flag)
+	}
+	return strings.Join(flags, " ")
+}
+
+// stdPackages returns the set of packages of the standard library. Only the
+// package names are loaded, the packages are neither parsed nor type checked.
+func (d *Linter) stdPackages(ctx context.Context) (map[string]struct{}, error) {
+	var key cache.StdKey
+	if d.cache != nil {
+		key = cache.StdKey{
+			GOROOT:     d.goEnv["GOROOT"],
+			GoVersion:  d.goEnv["GOVERSION"],
+			GOOS:       d.goEnv["GOOS"],
+			GOARCH:     d.goEnv["GOARCH"],
+			BuildFlags: buildFlags(d.goEnv["GOFLAGS"]),
+		}
+	}
+
+	var pkgPaths []string
+	ok := false
+	if d.cache != nil {
+		pkgPaths, ok = d.cache.GetStd(key)
+	}
+
+	if !ok {
+		stdPkgs, err := packages.Load(&packages.Config{Context: ctx, Mode: packages.NeedName, Dir: d.dir, Env: d.environ()}, "std")
+		if err != nil {
+			return nil, err
+		}
+
+		for _, pkg := range stdPkgs {
+			pkgPaths = append(pkgPaths, pkg.PkgPath)
+		}
+
+		if d.cache != nil {
+			// The cache is best effort, a failed write only means, that the
+			// standard library packages are loaded again in the next run.
+			_ = d.cache.PutStd(key, pkgPaths)
+		}
+	}
+
+	stdSet := make(map[string]struct{}, len(pkgPaths))
+	for _, pkgPath := range pkgPaths {
+		stdSet[pkgPath] = struct{}{}
+	}
+
+	return stdSet, nil
+}
+
+// classifierID identifies the classifier and the capslock version used for
+// the analysis. Without build info, e.g. for binaries built with
+// -buildvcs=false or run from a workspace, the version of capslock is unknown,
+// so the classifier is identified by the hash of its capability map as well.
+var classifierID = sync.OnceValue(func() string {
+	version := "(devel)"
+	if info, ok := debug.ReadBuildInfo(); ok {
+		for _, dep := range info.Deps {
+			if dep.Path == "github.com/google/capslock" {
+				version = dep.Version
+				break
+			}
+		}
+	}
+
+	// The maps of the classifier are printed sorted by key.
+	h := sha256.Sum256([]byte(fmt.Sprintf("%v", *analyzer.GetClassifier(true))))
+
+	return "capslock@" + version + " default classifier excluding unanalyzed " + hex.EncodeToString(h[:])
+})
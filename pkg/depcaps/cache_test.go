@@ -0,0 +1,203 @@
+package depcaps_test
+
+import (
+	"context"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+
+	"golang.org/x/mod/module"
+	modzip "golang.org/x/mod/zip"
+
+	"github.com/breml/depcaps/pkg/depcaps"
+)
+
+func TestCacheDependencyVersion(t *testing.T) {
+	proxy := t.TempDir()
+	writeProxyModule(t, proxy, "example.com/a", "v1.0.0", map[string]string{
+		"go.mod": "module example.com/a\n\ngo 1.21\n\nrequire example.com/b v1.0.0\n",
+		"a.go":   "package a\n\nimport \"example.com/b\"\n\nfunc A() { b.B() }\n",
+	})
+	writeProxyModule(t, proxy, "example.com/b", "v1.0.0", map[string]string{
+		"go.mod": "module example.com/b\n\ngo 1.21\n",
+		"b.go":   "package b\n\nfunc B() {}\n",
+	})
+	writeProxyModule(t, proxy, "example.com/b", "v1.1.0", map[string]string{
+		"go.mod": "module example.com/b\n\ngo 1.21\n",
+		"b.go":   "package b\n\nimport \"os/exec\"\n\nfunc B() { _ = exec.Command(\"true\").Run() }\n",
+	})
+
+	env := []string{
+		"GOPROXY=file://" + filepath.ToSlash(proxy),
+		"GOMODCACHE=" + t.TempDir(),
+		"GOFLAGS=-modcacherw",
+		"GONOSUMDB=example.com",
+		"GOSUMDB=off",
+		"GOWORK=off",
+	}
+	dir := t.TempDir()
+	err := os.WriteFile(filepath.Join(dir, "main.go"), []byte("package main\n\nimport \"example.com/a\"\n\nfunc main() { a.A() }\n"), 0o600)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	cacheDir := t.TempDir()
+
+	// The capabilities of example.com/a depend on the version of example.com/b,
+	// which is selected by the main module. The cached capabilities of
+	// example.com/a must not be used for another version of example.com/b.
+	for _, tc := range []struct {
+		version  string
+		wantExec bool
+	}{
+		{version: "v1.0.0"},
+		{version: "v1.1.0", wantExec: true},
+	} {
+		goMod := "module main\n\ngo 1.21\n\nrequire (\n\texample.com/a v1.0.0\n\texample.com/b " + tc.version + "\n)\n"
+		err := os.WriteFile(filepath.Join(dir, "go.mod"), []byte(goMod), 0o600)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		goCommand(t, dir, env, "mod", "tidy")
+
+		l := newLinter(t, nil, depcaps.WithCacheDir(cacheDir), depcaps.WithEnv(env...))
+		findings, err := l.Analyze(context.Background(), dir, "./...")
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		var gotExec bool
+		for _, f := range findings {
+			if f.Dependency == "example.com/a" && f.Capability.String() == "CAPABILITY_EXEC" {
+				gotExec = true
+			}
+		}
+		if gotExec != tc.wantExec {
+			t.Fatalf("expected CAPABILITY_EXEC of example.com/a with example.com/b %s to be %t, got: %+v", tc.version, tc.wantExec, findings)
+		}
+	}
+}
+
+// writeProxyModule writes the module path at version vers with files to the
+// GOPROXY directory proxy.
+func writeProxyModule(t *testing.T, proxy, path, vers string, files map[string]string) {
+	t.Helper()
+
+	src := t.TempDir()
+	for name, content := range files {
+		err := os.WriteFile(filepath.Join(src, name), []byte(content), 0o600)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+
+	escaped, err := module.EscapePath(path)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	dir := filepath.Join(proxy, escaped, "@v")
+	err = os.MkdirAll(dir, 0o755)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	err = os.WriteFile(filepath.Join(dir, vers+".mod"), []byte(files["go.mod"]), 0o600)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	err = os.WriteFile(filepath.Join(dir, vers+".info"), []byte(`{"Version":"`+vers+`"}`), 0o600)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	zf, err := os.Create(filepath.Join(dir, vers+".zip"))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	defer zf.Close()
+	err = modzip.CreateFromDir(zf, module.Version{Path: path, Version: vers}, src)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
+
+func TestCaseInsensitivePackages(t *testing.T) {
+	proxy := t.TempDir()
+	writeProxyModule(t, proxy, "example.com/Upper", "v1.0.0", map[string]string{
+		"go.mod":   "module example.com/Upper\n\ngo 1.21\n",
+		"upper.go": "package upper\n\nimport \"os/exec\"\n\nfunc Run() { _ = exec.Command(\"true\").Run() }\n",
+	})
+
+	env := []string{
+		"GOPROXY=file://" + filepath.ToSlash(proxy),
+		"GOMODCACHE=" + t.TempDir(),
+		"GOFLAGS=-modcacherw",
+		"GONOSUMDB=example.com",
+		"GOSUMDB=off",
+		"GOWORK=off",
+	}
+	dir := t.TempDir()
+	err := os.WriteFile(filepath.Join(dir, "go.mod"), []byte("module main\n\ngo 1.21\n\nrequire example.com/Upper v1.0.0\n"), 0o600)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	err = os.WriteFile(filepath.Join(dir, "main.go"), []byte("package main\n\nimport upper \"example.com/Upper\"\n\nfunc main() { upper.Run() }\n"), 0o600)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	goCommand(t, dir, env, "mod", "tidy")
+
+	// The keys of the settings are in lower case, like the inline settings of
+	// golangci-lint.
+	settings := &depcaps.LinterSettings{
+		PackageAllowedCapabilities: map[string]map[string]bool{
+			"example.com/upper": {"CAPABILITY_EXEC": true},
+		},
+	}
+
+	for _, tc := range []struct {
+		name          string
+		opts          []depcaps.Option
+		wantViolation bool
+	}{
+		{
+			name:          "case-sensitive",
+			wantViolation: true,
+		},
+		{
+			name: "case-insensitive",
+			opts: []depcaps.Option{depcaps.WithCaseInsensitivePackages()},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			opts := append([]depcaps.Option{depcaps.WithCacheDir(t.TempDir()), depcaps.WithEnv(env...)}, tc.opts...)
+			l := newLinter(t, settings, opts...)
+			findings, err := l.Analyze(context.Background(), dir, "./...")
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			var gotViolation bool
+			for _, f := range findings {
+				if f.Dependency == "example.com/Upper" && f.Capability.String() == "CAPABILITY_EXEC" && f.Decision == depcaps.Violation {
+					gotViolation = true
+				}
+			}
+			if gotViolation != tc.wantViolation {
+				t.Fatalf("expected CAPABILITY_EXEC violation of example.com/Upper to be %t, got: %+v", tc.wantViolation, findings)
+			}
+		})
+	}
+}
+
+// goCommand runs the go command with args in dir.
+func goCommand(t *testing.T, dir string, env []string, args ...string) {
+	t.Helper()
+
+	cmd := exec.Command("go", args...)
+	cmd.Dir = dir
+	cmd.Env = append(os.Environ(), env...)
+	out, err := cmd.CombinedOutput()
+	if err != nil {
+		t.Fatalf("go %v: %v\n%s", args, err, out)
+	}
+}
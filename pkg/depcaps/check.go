@@ -0,0 +1,32 @@
+package depcaps
+
+import (
+	"context"
+)
+
+// Check analyzes the packages of the module in dir with the settings and
+// returns the report of the capabilities of the dependencies, including the
+// allowed ones. The findings of the report hold the packages, capabilities,
+// decisions and call paths, the violations are returned by Violations.
+//
+// Check does not report any diagnostics, it runs the same analysis as the
+// analyzer returned by AsAnalyzer. The settings might be nil. If ctx is done
+// or the timeout is reached, the report of the packages analyzed so far is
+// returned together with the error.
+func Check(ctx context.Context, dir string, settings *LinterSettings, opts ...Option) (*Report, error) {
+	l, err := New(settings, append([]Option{WithDir(dir)}, opts...)...)
+	if err != nil {
+		return nil, err
+	}
+
+	findings, err := l.Analyze(ctx, dir, "./...")
+	if err != nil && !isContextError(err) {
+		return nil, err
+	}
+
+	report, reportErr := NewReport(dir, findings)
+	if reportErr != nil {
+		return nil, reportErr
+	}
+	return report, err
+}
@@ -0,0 +1,353 @@
+package depcaps_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"go/types"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"golang.org/x/tools/go/analysis"
+	"golang.org/x/tools/go/analysis/analysistest"
+
+	"github.com/breml/depcaps/pkg/depcaps"
+)
+
+func TestCheck(t *testing.T) {
+	// Check does not depend on the working directory.
+	dir := filepath.Join("..", "..", "testdata", "src", "alltest")
+
+	report, err := depcaps.Check(context.Background(), dir, nil, depcaps.WithCacheDir(t.TempDir()))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	var found bool
+	for _, f := range report.Violations() {
+		if f.Package == "alltest/allow" && f.Dependency == "github.com/google/uuid" && f.Capability.String() == "CAPABILITY_NETWORK" {
+			found = true
+			if f.Module != "github.com/google/uuid" || f.Version != "v1.3.1" {
+				t.Fatalf("expected module github.com/google/uuid@v1.3.1, got: %s@%s", f.Module, f.Version)
+			}
+			if len(f.Path) < 2 || f.Path[0].Package != "alltest/allow" {
+				t.Fatalf("expected call path starting in alltest/allow, got: %+v", f.Path)
+			}
+		}
+	}
+	if !found {
+		t.Fatalf("expected violation CAPABILITY_NETWORK of github.com/google/uuid in alltest/allow, got: %+v", report.Violations())
+	}
+	if len(report.Modules) == 0 {
+		t.Fatalf("expected modules in the report")
+	}
+}
+
+func TestCheckRequireExplicitApproval(t *testing.T) {
+	dir := filepath.Join("..", "..", "testdata", "src", "alltest")
+
+	tt := []struct {
+		name     string
+		indirect bool
+		// unapproved are in the order of go.mod.
+		unapproved []string
+	}{
+		{
+			name:       "direct",
+			unapproved: []string{"github.com/google/capslock"},
+		},
+		{
+			name:     "indirect",
+			indirect: true,
+			unapproved: []string{
+				"github.com/google/capslock",
+				"github.com/fatih/color",
+				"github.com/mattn/go-colorable",
+				"github.com/mattn/go-isatty",
+				"golang.org/x/mod",
+				"golang.org/x/tools",
+				"google.golang.org/protobuf",
+			},
+		},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			settings := &depcaps.LinterSettings{
+				// The empty entry approves the module, a package of the module
+				// approves the module as well.
+				PackageAllowedCapabilities: map[string]map[string]bool{
+					"github.com/google/uuid": {},
+				},
+				PackageDeniedCapabilities: map[string]map[string]depcaps.Severity{
+					"golang.org/x/sys/unix": {"CAPABILITY_EXEC": depcaps.SeverityError},
+				},
+				RequireExplicitApproval:         true,
+				RequireExplicitApprovalIndirect: tc.indirect,
+			}
+
+			report, err := depcaps.Check(context.Background(), dir, settings, depcaps.WithCacheDir(t.TempDir()))
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			var unapproved []string
+			for _, f := range report.Violations() {
+				if !f.Unapproved {
+					continue
+				}
+				unapproved = append(unapproved, f.Module)
+				if filepath.Base(f.Position.Filename) != "go.mod" || f.Position.Line == 0 {
+					t.Fatalf("expected finding at the require directive in go.mod, got: %s", f.Position)
+				}
+			}
+			if strings.Join(unapproved, " ") != strings.Join(tc.unapproved, " ") {
+				t.Fatalf("expected unapproved modules %v, got: %v", tc.unapproved, unapproved)
+			}
+
+			for _, mod := range report.Modules {
+				if mod.Path == "github.com/google/capslock" && !mod.Unapproved {
+					t.Fatalf("expected module github.com/google/capslock to be unapproved in the report")
+				}
+				if mod.Path == "github.com/google/uuid" && mod.Unapproved {
+					t.Fatalf("expected module github.com/google/uuid to be approved in the report")
+				}
+			}
+
+			var buf bytes.Buffer
+			err = depcaps.WriteText(&buf, report.Findings)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			want := "go.mod:6:2: error: Module github.com/google/capslock is not approved by the config\n"
+			if !strings.Contains(buf.String(), want) {
+				t.Fatalf("expected output to contain %q, got:\n%s", want, buf.String())
+			}
+		})
+	}
+}
+
+func TestCheckPerModule(t *testing.T) {
+	dir := filepath.Join("..", "..", "testdata", "src", "alltest")
+
+	report, err := depcaps.Check(context.Background(), dir, nil, depcaps.WithCacheDir(t.TempDir()), depcaps.WithPerModule(true))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	var merged *depcaps.Finding
+	for _, f := range report.Violations() {
+		// The problems of the analysis, e.g. of the malformed directives, are
+		// not capabilities of modules.
+		if len(f.Affected) == 0 && f.Problem == "" {
+			t.Fatalf("expected all the violations to be merged per module, got: %+v", f)
+		}
+		if f.Module == "github.com/google/uuid" && f.Capability.String() == "CAPABILITY_NETWORK" {
+			if merged != nil {
+				t.Fatalf("expected CAPABILITY_NETWORK of github.com/google/uuid to be reported once")
+			}
+			merged = &f
+		}
+	}
+	if merged == nil {
+		t.Fatalf("expected violation CAPABILITY_NETWORK of github.com/google/uuid, got: %+v", report.Violations())
+	}
+	if filepath.Base(merged.Position.Filename) != "go.mod" || merged.Position.Line != 7 {
+		t.Fatalf("expected finding at the require directive in go.mod, got: %s", merged.Position)
+	}
+	if merged.Package != "" || merged.Severity != depcaps.SeverityError {
+		t.Fatalf("expected finding without package and with severity error, got: %+v", merged)
+	}
+	for _, a := range merged.Affected {
+		if a.Module != merged.Module || a.Capability != merged.Capability || a.Package == "" {
+			t.Fatalf("expected affected finding of package reaching the capability, got: %+v", a)
+		}
+	}
+
+	// The report holds the findings of the affected packages, the allowed
+	// capabilities are not merged.
+	var violations int
+	for _, mod := range report.Modules {
+		for _, pkg := range mod.Packages {
+			for _, c := range pkg.Capabilities {
+				if pkg.Path != "github.com/google/uuid" || c.Capability != "CAPABILITY_NETWORK" {
+					continue
+				}
+				for _, reach := range c.ReachedBy {
+					if reach.Decision == depcaps.Violation {
+						violations++
+					}
+				}
+			}
+		}
+	}
+	if violations != len(merged.Affected) {
+		t.Fatalf("expected %d violations of CAPABILITY_NETWORK in the report, got: %d", len(merged.Affected), violations)
+	}
+
+	var buf bytes.Buffer
+	err = depcaps.WriteText(&buf, report.Findings)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	for _, want := range []string{
+		"go.mod:7:2: error: Module github.com/google/uuid has not allowed capability CAPABILITY_NETWORK, reached by 3 packages\n",
+		": reached by package alltest/allow\n",
+		"\t... 1 more\n",
+	} {
+		if !strings.Contains(buf.String(), want) {
+			t.Fatalf("expected output to contain %q, got:\n%s", want, buf.String())
+		}
+	}
+}
+
+func TestCheckCanceled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	dir := filepath.Join("..", "..", "testdata", "src", "alltest")
+	_, err := depcaps.Check(ctx, dir, nil, depcaps.WithCacheDir(t.TempDir()))
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("expected context.Canceled, got: %v", err)
+	}
+}
+
+func TestAnalyzeTimeout(t *testing.T) {
+	dir := filepath.Join("..", "..", "testdata", "src", "alltest")
+
+	l := newLinter(t, nil, depcaps.WithCacheDir(t.TempDir()), depcaps.WithTimeout(time.Nanosecond))
+	_, err := l.Analyze(context.Background(), dir, "./allow/...")
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("expected context.DeadlineExceeded, got: %v", err)
+	}
+}
+
+func TestAnalyzeDir(t *testing.T) {
+	dir := filepath.Join("..", "..", "testdata", "src", "alltest")
+	goMod, err := filepath.Abs(filepath.Join(dir, "go.mod"))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	// The module in the directory passed to Analyze is the main module,
+	// regardless of the working directory and the directory of WithDir.
+	for name, opts := range map[string][]depcaps.Option{
+		"working directory": nil,
+		"other directory":   {depcaps.WithDir(t.TempDir())},
+	} {
+		t.Run(name, func(t *testing.T) {
+			opts := append([]depcaps.Option{depcaps.WithCacheDir(t.TempDir()), depcaps.WithPerModule(true)}, opts...)
+			l := newLinter(t, nil, opts...)
+			findings, err := l.Analyze(context.Background(), dir, "./allow/...")
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			var found bool
+			for _, f := range findings {
+				if f.Module != "github.com/google/uuid" || f.Capability.String() != "CAPABILITY_NETWORK" {
+					continue
+				}
+				found = true
+				if f.Position.Filename != goMod || f.Position.Line != 7 {
+					t.Fatalf("expected finding at the require directive in %s, got: %s", goMod, f.Position)
+				}
+			}
+			if !found {
+				t.Fatalf("expected violation CAPABILITY_NETWORK of github.com/google/uuid, got: %+v", findings)
+			}
+		})
+	}
+}
+
+func TestAnalyzeDriverInSync(t *testing.T) {
+	testCaseDir := chdirTestdata(t, "alltest")
+	l := newLinter(t, nil, depcaps.WithCacheDir(t.TempDir()))
+
+	// The violations of Analyze are the diagnostics of the analysis driver.
+	// The test variants of the packages report the same diagnostics again,
+	// which the analysis driver prints only once.
+	seen := make(map[string]bool)
+	var want []string
+	for _, result := range analysistest.Run(t, testCaseDir, l.AsAnalyzer(false), "./simple/...") {
+		for _, diag := range result.Diagnostics {
+			key := result.Pass.Fset.Position(diag.Pos).String() + " " + diag.Category
+			if !seen[key] {
+				seen[key] = true
+				want = append(want, key)
+			}
+		}
+	}
+
+	findings, err := l.Analyze(context.Background(), testCaseDir, "./simple/...")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	var got []string
+	for _, f := range findings {
+		if f.Decision == depcaps.Violation {
+			got = append(got, f.Position.String()+" "+f.Capability.String())
+		}
+	}
+
+	sort.Strings(want)
+	sort.Strings(got)
+	if len(want) == 0 || strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Fatalf("expected violations at the diagnostics of the analysis driver:\n%s\ngot:\n%s", strings.Join(want, "\n"), strings.Join(got, "\n"))
+	}
+}
+
+func TestAnalyzerContext(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	dir := filepath.Join("..", "..", "testdata", "src", "alltest")
+	pass := &analysis.Pass{Pkg: types.NewPackage("alltest/allow", "allow")}
+
+	// The context of the analyzer stops the analysis of every package.
+	l := newLinter(t, nil, depcaps.WithCacheDir(t.TempDir()), depcaps.WithContext(ctx))
+	_, err := l.AsAnalyzer(false).Run(pass)
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("expected context.Canceled, got: %v", err)
+	}
+
+	// Analyze uses the context passed to it instead.
+	findings, err := l.Analyze(context.Background(), dir, "./allow/...")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(findings) == 0 {
+		t.Fatalf("expected findings")
+	}
+}
+
+func TestAnalyzerClose(t *testing.T) {
+	pass := &analysis.Pass{Pkg: types.NewPackage("alltest/allow", "allow")}
+
+	// Close stops the analysis of the analyzer before the timeout.
+	l := newLinter(t, nil, depcaps.WithCacheDir(t.TempDir()), depcaps.WithTimeout(time.Hour))
+	l.Close()
+	_, err := l.AsAnalyzer(false).Run(pass)
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("expected context.Canceled, got: %v", err)
+	}
+}
+
+func TestAnalyzeMalformedBaseline(t *testing.T) {
+	baseline, err := filepath.Abs(filepath.Join("testdata", "baseline_invalid_name.json"))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	dir := filepath.Join("..", "..", "testdata", "src", "alltest")
+
+	// The baseline file of the settings is read by Init, the error is
+	// returned instead of a panic.
+	l := newLinter(t, &depcaps.LinterSettings{CapslockBaselineFile: baseline}, depcaps.WithCacheDir(t.TempDir()))
+	_, err = l.Analyze(context.Background(), dir, "./allow/...")
+	if err == nil || !strings.Contains(err.Error(), `invalid function name "GetTime"`) {
+		t.Fatalf("expected error for the malformed baseline, got: %v", err)
+	}
+}
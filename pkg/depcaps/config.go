@@ -4,6 +4,7 @@ import (
 	"encoding/json"
 	"fmt"
 	"os"
+	"strings"
 
 	"github.com/google/capslock/proto"
 )
@@ -11,7 +12,35 @@ import (
 type LinterSettings struct {
 	GlobalAllowedCapabilities  map[string]bool            `json:"GlobalAllowedCapabilities"`
 	PackageAllowedCapabilities map[string]map[string]bool `json:"PackageAllowedCapabilities"`
-	CapslockBaselineFile       string                     `json:"-"`
+
+	// GlobalCapabilitySeverity and PackageCapabilitySeverity set the severity
+	// of not allowed capabilities, the default severity is error.
+	GlobalCapabilitySeverity  map[string]Severity            `json:"GlobalCapabilitySeverity"`
+	PackageCapabilitySeverity map[string]map[string]Severity `json:"PackageCapabilitySeverity"`
+
+	// GlobalDeniedCapabilities and PackageDeniedCapabilities deny
+	// capabilities with the given severity, even if they are allowed or
+	// covered by the baseline.
+	GlobalDeniedCapabilities  map[string]Severity            `json:"GlobalDeniedCapabilities"`
+	PackageDeniedCapabilities map[string]map[string]Severity `json:"PackageDeniedCapabilities"`
+
+	// RequireExplicitApproval requires every direct dependency module to be
+	// listed in the config, even if it has no capabilities of interest, and
+	// RequireExplicitApprovalIndirect the indirect dependency modules as well.
+	// A module is listed, if the module path or the path of a package of the
+	// module is a key of PackageAllowedCapabilities,
+	// PackageCapabilitySeverity or PackageDeniedCapabilities. Modules, which
+	// are not listed, are reported at their require directive in go.mod.
+	RequireExplicitApproval         bool `json:"RequireExplicitApproval"`
+	RequireExplicitApprovalIndirect bool `json:"RequireExplicitApprovalIndirect"`
+
+	CapslockBaselineFile string `json:"-"`
+
+	configFile string
+	// foldPackages is true, if the package paths of the package rules are
+	// converted to lower case and matched case-insensitively, see
+	// WithCaseInsensitivePackages.
+	foldPackages bool
 }
 
 func (s LinterSettings) IsBoolFlag() bool { return false }
@@ -30,7 +59,92 @@ func (s *LinterSettings) Set(in string) error {
 	if err != nil {
 		return err
 	}
+	s.configFile = in
 
+	return s.Validate()
+}
+
+// clone returns a deep copy of s. A nil s results in empty settings.
+func (s *LinterSettings) clone() *LinterSettings {
+	c := &LinterSettings{
+		GlobalAllowedCapabilities:  map[string]bool{},
+		PackageAllowedCapabilities: map[string]map[string]bool{},
+	}
+	if s == nil {
+		return c
+	}
+
+	for k, v := range s.GlobalAllowedCapabilities {
+		c.GlobalAllowedCapabilities[k] = v
+	}
+	for pkg, caps := range s.PackageAllowedCapabilities {
+		c.PackageAllowedCapabilities[pkg] = cloneMap(caps)
+	}
+	c.GlobalCapabilitySeverity = cloneMap(s.GlobalCapabilitySeverity)
+	c.PackageCapabilitySeverity = clonePackageMap(s.PackageCapabilitySeverity)
+	c.GlobalDeniedCapabilities = cloneMap(s.GlobalDeniedCapabilities)
+	c.PackageDeniedCapabilities = clonePackageMap(s.PackageDeniedCapabilities)
+	c.RequireExplicitApproval = s.RequireExplicitApproval
+	c.RequireExplicitApprovalIndirect = s.RequireExplicitApprovalIndirect
+	c.CapslockBaselineFile = s.CapslockBaselineFile
+	c.configFile = s.configFile
+	c.foldPackages = s.foldPackages
+
+	return c
+}
+
+// foldPackageCase converts the package paths of the package rules to lower
+// case, such that they are matched case-insensitively.
+func (s *LinterSettings) foldPackageCase() {
+	s.PackageAllowedCapabilities = lowerKeys(s.PackageAllowedCapabilities)
+	s.PackageCapabilitySeverity = lowerKeys(s.PackageCapabilitySeverity)
+	s.PackageDeniedCapabilities = lowerKeys(s.PackageDeniedCapabilities)
+	s.foldPackages = true
+}
+
+// packageKey returns the key of the package rules for the package path pkg.
+func (s *LinterSettings) packageKey(pkg string) string {
+	if s.foldPackages {
+		return strings.ToLower(pkg)
+	}
+	return pkg
+}
+
+func lowerKeys[V any](m map[string]V) map[string]V {
+	if m == nil {
+		return nil
+	}
+	lower := make(map[string]V, len(m))
+	for k, v := range m {
+		lower[strings.ToLower(k)] = v
+	}
+	return lower
+}
+
+func cloneMap[V any](m map[string]V) map[string]V {
+	if m == nil {
+		return nil
+	}
+	c := make(map[string]V, len(m))
+	for k, v := range m {
+		c[k] = v
+	}
+	return c
+}
+
+func clonePackageMap[V any](m map[string]map[string]V) map[string]map[string]V {
+	if m == nil {
+		return nil
+	}
+	c := make(map[string]map[string]V, len(m))
+	for pkg, caps := range m {
+		c[pkg] = cloneMap(caps)
+	}
+	return c
+}
+
+// Validate returns an error, if the settings contain an unknown capability.
+func (s *LinterSettings) Validate() error {
 	for c := range s.GlobalAllowedCapabilities {
 		if _, ok := proto.Capability_value[c]; !ok {
 			return fmt.Errorf("invalid global capability: %s", c)
@@ -45,5 +159,23 @@ func (s *LinterSettings) Set(in string) error {
 		}
 	}
 
+	for _, rules := range []map[string]Severity{s.GlobalCapabilitySeverity, s.GlobalDeniedCapabilities} {
+		for c := range rules {
+			if _, ok := proto.Capability_value[c]; !ok {
+				return fmt.Errorf("invalid global capability: %s", c)
+			}
+		}
+	}
+
+	for _, rules := range []map[string]map[string]Severity{s.PackageCapabilitySeverity, s.PackageDeniedCapabilities} {
+		for p, pv := range rules {
+			for c := range pv {
+				if _, ok := proto.Capability_value[c]; !ok {
+					return fmt.Errorf("invalid capability for package %q: %s", p, c)
+				}
+			}
+		}
+	}
+
 	return nil
 }
@@ -50,6 +50,11 @@ func TestLinterSettingsSetError(t *testing.T) {
 			filename: "testdata/invalid_package_capability.json",
 			wantErr:  true,
 		},
+		{
+			name:     "invalid severity",
+			filename: "testdata/invalid_severity.json",
+			wantErr:  true,
+		},
 	}
 
 	for _, tc := range tt {
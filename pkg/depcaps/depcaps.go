@@ -1,65 +1,109 @@
 package depcaps
 
 import (
+	"context"
 	"flag"
 	"fmt"
 	"go/token"
 	"os"
+	"reflect"
+	"sort"
 	"strings"
 	"sync"
+	"time"
 
 	"github.com/google/capslock/analyzer"
 	"github.com/google/capslock/proto"
 	"golang.org/x/mod/modfile"
 	"golang.org/x/tools/go/analysis"
-	"golang.org/x/tools/go/packages"
 	"google.golang.org/protobuf/encoding/protojson"
 
+	"github.com/breml/depcaps/pkg/cache"
 	"github.com/breml/depcaps/pkg/module"
 )
 
-type depcaps struct {
+// Linter maps the capabilities of the dependencies against the allowed
+// capabilities of its settings. A Linter is created with New and used either
+// as analyzer or directly with Analyze.
+type Linter struct {
 	*LinterSettings
 
-	flagArgs bool
-	args     []string
-	packages []*packages.Package
+	dir             string
+	env             []string
+	cacheDir        string
+	noCache         bool
+	failUnplaceable bool
+	perModule       bool
+	caseInsensitive bool
+	timeout         time.Duration
+
+	// ctx is the context of the analysis, if used as analyzer, which does
+	// not provide a context. The timeout is applied on first use.
+	ctx        context.Context
+	ctxOnce    *sync.Once
+	ctxTimeout context.Context
+	ctxCancel  context.CancelFunc
 
 	once       *sync.Once
-	mu         *sync.Mutex
 	stdSet     map[string]struct{}
-	moduleFile *modfile.File
-	cil        *proto.CapabilityInfoList
-	baseline   *proto.CapabilityInfoList
+	cache      *cache.Cache
+	goEnv      map[string]string
+	sums       map[string]string
+	classifier analyzer.Classifier
+	baseline   capabilityIndex
+	mainModule string
+	modFile    *modfile.File
+	approval   string
+	config     *configFile
+
+	// files holds the files added to the file sets of the analysis, like
+	// go.mod, the config file and the files of dependencies.
+	filesMu *sync.Mutex
+	files   map[fileKey]*token.File
 }
 
-func New(settings *LinterSettings) *depcaps {
-	depcaps := &depcaps{
-		LinterSettings: &LinterSettings{
-			GlobalAllowedCapabilities:  map[string]bool{},
-			PackageAllowedCapabilities: map[string]map[string]bool{},
-		},
+// New returns a Linter for the settings, which are copied, such that several
+// independently configured Linters can be used in the same process. The
+// settings might be nil.
+func New(settings *LinterSettings, opts ...Option) (*Linter, error) {
+	l := &Linter{
+		LinterSettings: settings.clone(),
+
+		ctx:     context.Background(),
+		once:    &sync.Once{},
+		ctxOnce: &sync.Once{},
+		filesMu: &sync.Mutex{},
+	}
+
+	err := l.Validate()
+	if err != nil {
+		return nil, err
+	}
 
-		once:   &sync.Once{},
-		mu:     &sync.Mutex{},
-		stdSet: make(map[string]struct{}),
+	for _, opt := range opts {
+		err = opt(l)
+		if err != nil {
+			return nil, err
+		}
 	}
 
-	if settings != nil {
-		depcaps.GlobalAllowedCapabilities = settings.GlobalAllowedCapabilities
-		depcaps.PackageAllowedCapabilities = settings.PackageAllowedCapabilities
-		depcaps.CapslockBaselineFile = settings.CapslockBaselineFile
+	// The settings might be replaced by an option, so they are folded after
+	// all the options are applied.
+	if l.caseInsensitive {
+		l.LinterSettings.foldPackageCase()
 	}
 
-	return depcaps
+	return l, nil
 }
 
-func (d *depcaps) AsAnalyzer(withFlags bool) *analysis.Analyzer {
+func (d *Linter) AsAnalyzer(withFlags bool) *analysis.Analyzer {
 	a := &analysis.Analyzer{
-		Name:     "depcaps",
-		Doc:      "depcaps maps capabilities of dependencies agains a set of allowed capabilities",
-		Run:      d.run,
-		Requires: []*analysis.Analyzer{},
+		Name:       "depcaps",
+		Doc:        "depcaps maps capabilities of dependencies agains a set of allowed capabilities",
+		Run:        d.run,
+		Requires:   []*analysis.Analyzer{},
+		ResultType: reflect.TypeOf([]Finding(nil)),
+		FactTypes:  []analysis.Fact{new(packageCapabilities)},
 	}
 
 	if withFlags {
@@ -67,132 +111,155 @@ func (d *depcaps) AsAnalyzer(withFlags bool) *analysis.Analyzer {
 		a.Flags.Var(versionFlag{}, "V", "print version and exit")
 		a.Flags.Var(d.LinterSettings, "config", "depcaps linter settings config file")
 		a.Flags.StringVar(&d.CapslockBaselineFile, "reference", "", "capslock capabilities reference file")
+		a.Flags.StringVar(&d.cacheDir, "cachedir", "", "directory of the capabilities cache (default: depcaps in the user cache directory)")
+		a.Flags.BoolVar(&d.noCache, "nocache", false, "disable the capabilities cache")
+		a.Flags.BoolVar(&d.failUnplaceable, "failunplaceable", false, "fail, if a finding can neither be placed at a call site nor at an import")
+		a.Flags.DurationVar(&d.timeout, "timeout", 0, "stop the analysis after the timeout and report the results of the packages analyzed so far (default: no timeout)")
 	}
 
 	return a
 }
 
-func (d *depcaps) WithPackages(pkgs []*packages.Package) *depcaps {
-	d.packages = pkgs
-	return d
-}
-
-func (d *depcaps) WithArgs(args []string) *depcaps {
-	d.args = args
-	return d
-}
-
-func (d *depcaps) WithFlagArgs() *depcaps {
-	d.flagArgs = true
-	return d
-}
-
-func (d *depcaps) WithBaselineFile(baselineFile string) *depcaps {
-	d.mu.Lock()
-	defer d.mu.Unlock()
-
-	err := d.readCapslockBaseline(baselineFile)
-	if err != nil {
-		panic(err)
-	}
-
-	return d
-}
-
-func (d *depcaps) Init() error {
+// Init prepares the analysis, it is called by the analyzer before the first
+// package is analyzed. ctx bounds the go commands run by Init.
+func (d *Linter) Init(ctx context.Context) error {
 	var err error
 	d.once.Do(func() {
-		d.mu.Lock()
-		defer d.mu.Unlock()
+		if !d.noCache {
+			// Without a usable cache directory, the analysis is still possible,
+			// it is just slower.
+			d.cache, _ = cache.New(d.cacheDir)
+		}
+
+		if d.cache != nil {
+			d.goEnv, err = module.GetGoEnv(ctx, d.dir, d.env, "GOROOT", "GOVERSION", "GOOS", "GOARCH", "CGO_ENABLED", "GOFLAGS")
+			if err != nil {
+				return // err is returned after the once.Do-block
+			}
+
+			d.sums, err = module.GetModuleSums(ctx, d.dir, d.env)
+			if err != nil {
+				return // err is returned after the once.Do-block
+			}
+		}
 
 		// init std pkg list
-		var stdPkgs []*packages.Package
-		stdPkgs, err = packages.Load(&packages.Config{Tests: false}, "std")
+		d.stdSet, err = d.stdPackages(ctx)
 		if err != nil {
 			return // error is returned after the once.Do-block
 		}
 
-		pre := func(pkg *packages.Package) bool {
-			d.stdSet[pkg.PkgPath] = struct{}{}
-			return true
-		}
-		packages.Visit(stdPkgs, pre, nil)
+		d.classifier = analyzer.GetClassifier(true)
 
-		// init moduleFile
-		d.moduleFile, err = module.GetModuleFile()
-		if err != nil {
-			return // err is returned after the once.Do-block
+		// The path of the main module is only needed, if the driver does not
+		// provide the module of the analyzed package. Without a main module,
+		// e.g. in GOPATH mode, the package path is used instead. The module
+		// file provides the positions of the require directives.
+		if mf, err := module.GetModuleFile(ctx, d.dir, d.env); err == nil && mf.Module != nil {
+			d.mainModule = mf.Module.Mod.Path
+			d.modFile = mf
 		}
 
-		packageNames := []string{"."}
-		if d.flagArgs {
-			packageNames = flag.Args()
-		}
-		if len(d.args) > 0 {
-			packageNames = d.args
+		if d.RequireExplicitApproval && d.modFile != nil {
+			d.approval, err = d.approvalPackage(ctx)
+			if err != nil {
+				return // err is returned after the once.Do-block
+			}
 		}
 
-		var classifier analyzer.Classifier = analyzer.GetClassifier(true)
-
-		pkgs := d.packages
-		if len(pkgs) == 0 {
-			pkgs, err = analyzer.LoadPackages(packageNames,
-				analyzer.LoadConfig{
-					// TODO: support BuildTags, GOOS and GOARCH?
-					// 	BuildTags: *buildTags,
-					// 	GOOS:      *goos,
-					// 	GOARCH:    *goarch,
-				},
-			)
+		if d.baseline == nil {
+			err = d.readCapslockBaseline(d.CapslockBaselineFile)
 			if err != nil {
-				return
+				return // err is returned after the once.Do.block
 			}
 		}
-		if len(pkgs) == 0 {
-			err = fmt.Errorf("no packages matching %v", packageNames)
-			return // err is returned after the once.Do.block
-		}
-
-		queriedPackages := analyzer.GetQueriedPackages(pkgs)
-		d.cil = analyzer.GetCapabilityInfo(pkgs, queriedPackages, &analyzer.Config{
-			Classifier:     classifier,
-			DisableBuiltin: false,
-		})
 
-		err = d.readCapslockBaseline(d.CapslockBaselineFile)
-		if err != nil {
-			return // err is returned after the once.Do.block
+		// The suggested fixes edit the config file, which is parsed once.
+		if d.configFile != "" {
+			d.config, err = readConfigFile(d.configFile)
+			if err != nil {
+				return // err is returned after the once.Do-block
+			}
 		}
 	})
 	return err // return err from once.Do-block
 }
 
-func (d *depcaps) run(pass *analysis.Pass) (interface{}, error) {
-	err := d.Init()
+func (d *Linter) run(pass *analysis.Pass) (interface{}, error) {
+	return d.runContext(d.context(), pass)
+}
+
+// runContext analyzes the package of pass. If ctx is done, the analysis of the
+// package fails.
+func (d *Linter) runContext(ctx context.Context, pass *analysis.Pass) (interface{}, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, fmt.Errorf("analysis of package %s stopped: %w", pass.Pkg.Path(), err)
+	}
+
+	err := d.Init(ctx)
 	if err != nil {
 		return nil, err
 	}
 
-	if isTestPackage(pass) {
-		return nil, nil
+	packageName := pass.Pkg.Path()
+	packagePrefix := d.modulePath(pass)
+
+	key, cacheable := d.cacheKey(pass)
+	if cacheable {
+		if data, ok := d.cache.Get(key); ok {
+			fact := &packageCapabilities{}
+			if err := fact.GobDecode(data); err == nil {
+				fact.keyID = key.ID()
+				// Packages of dependency modules are only analyzed for their facts.
+				pass.ExportPackageFact(fact)
+				return []Finding(nil), nil
+			}
+		}
 	}
 
-	packageName := pass.Pkg.Path()
-	packagePrefix := pass.Pkg.Path()
-	if d.moduleFile != nil {
-		packagePrefix = d.getModulePath()
+	// The packages of the main module are the roots of the program, only
+	// their findings depend on the types of the whole program.
+	s := summarize(pass, d.classifier, d.isMainModule(pass) && !isTestPackage(pass))
+	if !d.isMainModule(pass) {
+		// Only the capabilities of dependencies are of interest for the
+		// packages depending on them, capabilities gained through other
+		// packages of the own module are reported in these packages.
+		fact := s.fact()
+		if cacheable {
+			fact.keyID = key.ID()
+		}
+		pass.ExportPackageFact(fact)
+		if cacheable {
+			// The cache is best effort, a failed write only means, that the
+			// package is analyzed again in the next run.
+			if data, err := fact.GobEncode(); err == nil {
+				_ = d.cache.Put(key, data)
+			}
+		}
+	}
+
+	if isTestPackage(pass) {
+		return []Finding(nil), nil
 	}
 
-	d.mu.Lock()
-	defer d.mu.Unlock()
+	directives, problems := importDirectives(pass)
+
+	current := &proto.CapabilityInfoList{
+		CapabilityInfo: s.dependencyCalls(func(pkgPath string) bool {
+			_, ok := d.stdSet[pkgPath]
+			return !ok && !strings.HasPrefix(pkgPath, packagePrefix)
+		}),
+	}
 
 	offendingCapabilities := make(map[string]map[proto.Capability]struct{})
 	if d.baseline != nil {
-		offendingCapabilities = diffCapabilityInfoLists(d.baseline, d.cil, packageName, packagePrefix)
+		offendingCapabilities = diffCapabilityInfoLists(d.baseline, current, packageName, packagePrefix)
 	}
 
-	for _, ci := range d.cil.GetCapabilityInfo() {
-		depPkg, skip := relevantCapabilityInfo(ci, packageName, packagePrefix)
+	for _, ci := range current.GetCapabilityInfo() {
+		// The summary sets the package of every function of the path, so the
+		// capability infos are never malformed.
+		depPkg, skip, _ := relevantCapabilityInfo(ci, packageName, packagePrefix)
 		if !skip {
 			continue
 		}
@@ -209,7 +276,7 @@ func (d *depcaps) run(pass *analysis.Pass) (interface{}, error) {
 			delete(offendingCapabilities[depPkg], ci.GetCapability())
 			continue
 		}
-		if pkgAllowedCaps, ok := d.PackageAllowedCapabilities[depPkg]; ok {
+		if pkgAllowedCaps, ok := d.PackageAllowedCapabilities[d.packageKey(depPkg)]; ok {
 			if ok := pkgAllowedCaps[ci.Capability.String()]; ok {
 				delete(offendingCapabilities[depPkg], ci.GetCapability())
 				continue
@@ -222,27 +289,153 @@ func (d *depcaps) run(pass *analysis.Pass) (interface{}, error) {
 		offendingCapabilities[depPkg][ci.GetCapability()] = struct{}{}
 	}
 
-	// TODO: sort offendingCapabilities by package name and capability name before reporting
-	for pkg, pkgCaps := range offendingCapabilities {
-		for cap := range pkgCaps {
-			pos := findPos(pass, pkg)
-			if pos == 0 {
-				// TODO: figure out, if and why this is necessary
-				// skip offending capabilites, that can not be matched to a source code location
+	findings := groupCapabilityInfos(current, packageName, packagePrefix)
+
+	// Report sorted by package and capability, such that the output is
+	// reproducible. offendingCapabilities holds all the relevant dependency
+	// packages, even if all their capabilities are allowed.
+	pkgs := make([]string, 0, len(offendingCapabilities))
+	for pkg := range offendingCapabilities {
+		pkgs = append(pkgs, pkg)
+	}
+	sort.Strings(pkgs)
+
+	type finding struct {
+		pkg      string
+		cap      proto.Capability
+		decision Decision
+		severity Severity
+		placement
+	}
+	var reported []finding
+	for _, pkg := range pkgs {
+		caps := make(map[proto.Capability]struct{}, len(findings[pkg]))
+		for cap := range findings[pkg] {
+			caps[cap] = struct{}{}
+		}
+		for cap := range offendingCapabilities[pkg] {
+			caps[cap] = struct{}{}
+		}
+
+		for _, cap := range sortedCapabilityNames(caps) {
+			decision := Violation
+			severity, denied := d.denied(pkg, cap)
+			if _, ok := offendingCapabilities[pkg][cap]; !ok && !denied {
+				decision = d.allowedBy(pkg, cap)
+			}
+			if decision == Violation && !denied && allowedByDirective(directives, pkg, cap) {
+				decision = AllowedDirective
+			}
+			if decision == Violation && !denied {
+				severity = d.severity(pkg, cap)
+			}
+
+			p, ok := d.place(pass, pkg, findings[pkg][cap])
+			if !ok {
+				continue
+			}
+			reported = append(reported, finding{pkg: pkg, cap: cap, decision: decision, severity: severity, placement: p})
+			if decision != Violation {
 				continue
 			}
+			if p.unplaceable && d.failUnplaceable {
+				return nil, fmt.Errorf("package %s has not allowed capability %s, which can not be placed at a call site or an import in package %s", pkg, cap, packageName)
+			}
+		}
+	}
+
+	// All the findings of a dependency package share the same fix, see
+	// fixCapabilities.
+	fixes := make(map[string][]analysis.SuggestedFix)
+	fixed := make(map[string]map[proto.Capability]bool)
+	for _, f := range reported {
+		if _, ok := fixed[f.pkg]; ok || f.decision != Violation {
+			continue
+		}
+		caps := d.fixCapabilities(s.facts[f.pkg], f.pkg)
+		fixed[f.pkg] = make(map[proto.Capability]bool, len(caps))
+		for _, c := range caps {
+			fixed[f.pkg][c] = true
+		}
+		fixes[f.pkg], err = d.suggestedFixes(pass, f.pkg, caps)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	// The result contains the allowed capabilities as well, only the not
+	// allowed capabilities are reported as diagnostics.
+	result := make([]Finding, 0, len(reported))
+	for _, f := range reported {
+		result = append(result, newFinding(pass, f.pkg, f.cap, f.decision, f.severity, f.placement, findings[f.pkg][f.cap]))
+		if f.decision != Violation {
+			continue
+		}
+
+		message := result[len(result)-1].message()
+		if f.severity != SeverityError {
+			// Errors are the default, only lower severities are called out.
+			message = fmt.Sprintf("%s: %s", f.severity, message)
+		}
 
-			pass.Report(analysis.Diagnostic{
-				Pos:     pos,
-				Message: fmt.Sprintf("Package %s has not allowed capability %s", pkg, cap),
-			})
+		var fix []analysis.SuggestedFix
+		if fixed[f.pkg][f.cap] {
+			fix = fixes[f.pkg]
 		}
+
+		pass.Report(analysis.Diagnostic{
+			Pos:            f.pos,
+			Category:       f.cap.String(),
+			Message:        message,
+			Related:        d.relatedInformation(pass, f.ci),
+			SuggestedFixes: fix,
+		})
 	}
 
-	return nil, nil
+	problems = append(problems, reportUnusedDirectives(pass, directives)...)
+
+	result = append(result, problems...)
+	result = append(result, d.unapprovedModules(pass)...)
+
+	return result, nil
+}
+
+// context returns the context of the analysis, if the Linter is used as
+// analyzer, with the timeout applied. The timeout starts with the first call.
+func (d *Linter) context() context.Context {
+	d.ctxOnce.Do(func() {
+		if d.timeout > 0 {
+			d.ctxTimeout, d.ctxCancel = context.WithTimeout(d.ctx, d.timeout)
+			return
+		}
+		d.ctxTimeout, d.ctxCancel = context.WithCancel(d.ctx)
+	})
+	return d.ctxTimeout
 }
 
-func (d *depcaps) readCapslockBaseline(capslockBaselineFile string) error {
+// Close cancels the context of the analysis, if the Linter is used as
+// analyzer, and releases the timer of the timeout. The analysis of the
+// packages, which are analyzed afterwards, fails. Analyze is not affected.
+func (d *Linter) Close() {
+	d.context()
+	d.ctxCancel()
+}
+
+// allowedBy returns the decision, by which the capability c of depPkg is
+// allowed, if it is not offending. The config takes precedence over the
+// baseline.
+func (d *Linter) allowedBy(depPkg string, c proto.Capability) Decision {
+	switch {
+	case d.GlobalAllowedCapabilities[c.String()]:
+		return AllowedGlobal
+	case d.PackageAllowedCapabilities[d.packageKey(depPkg)][c.String()]:
+		return AllowedPackage
+	default:
+		return AllowedBaseline
+	}
+}
+
+func (d *Linter) readCapslockBaseline(capslockBaselineFile string) error {
 	if capslockBaselineFile == "" {
 		return nil
 	}
@@ -251,19 +444,59 @@ func (d *depcaps) readCapslockBaseline(capslockBaselineFile string) error {
 	if err != nil {
 		return fmt.Errorf("Error reading baseline file: %v", err)
 	}
-	d.baseline = &proto.CapabilityInfoList{}
-	err = protojson.Unmarshal(baselineData, d.baseline)
+	baseline := &proto.CapabilityInfoList{}
+	err = protojson.Unmarshal(baselineData, baseline)
 	if err != nil {
 		return fmt.Errorf("Baseline file should include output from running `capslock -output=j`. Error parsing baseline file: %v", err)
 	}
+	d.baseline, err = newCapabilityIndex(baseline)
+	if err != nil {
+		return fmt.Errorf("Error in baseline file %s: %w", capslockBaselineFile, err)
+	}
 	return nil
 }
 
-func (d *depcaps) getModulePath() string {
-	d.mu.Lock()
-	defer d.mu.Unlock()
+// environ returns the environment of the go commands run for the analysis or
+// nil for the environment of the process.
+func (d *Linter) environ() []string {
+	if len(d.env) == 0 {
+		return nil
+	}
+	return append(os.Environ(), d.env...)
+}
 
-	return d.moduleFile.Module.Mod.Path
+// modulePath returns the path of the module of the package analyzed by pass.
+// If the driver does not provide the module, e.g. golangci-lint, packages of
+// the main module are identified by their path. Without module information,
+// e.g. in GOPATH mode, the package path is returned.
+func (d *Linter) modulePath(pass *analysis.Pass) string {
+	if pass.Module != nil && pass.Module.Path != "" {
+		return pass.Module.Path
+	}
+	if pass.Module == nil && d.inMainModule(pass.Pkg.Path()) {
+		return d.mainModule
+	}
+
+	return pass.Pkg.Path()
+}
+
+// isMainModule reports, if the package analyzed by pass belongs to the main
+// module. Only the main module has no version, packages of the standard
+// library have neither path nor version.
+func (d *Linter) isMainModule(pass *analysis.Pass) bool {
+	if pass.Module == nil {
+		return d.inMainModule(pass.Pkg.Path())
+	}
+	return pass.Module.Path != "" && pass.Module.Version == ""
+}
+
+// inMainModule reports, if pkgPath is the path of a package of the main
+// module.
+func (d *Linter) inMainModule(pkgPath string) bool {
+	if d.mainModule == "" {
+		return false
+	}
+	return pkgPath == d.mainModule || strings.HasPrefix(pkgPath, d.mainModule+"/")
 }
 
 func isTestPackage(pass *analysis.Pass) bool {
@@ -274,52 +507,61 @@ func isTestPackage(pass *analysis.Pass) bool {
 	return false
 }
 
-func relevantCapabilityInfo(ci *proto.CapabilityInfo, packageName, packagePrefix string) (string, bool) {
+// relevantCapabilityInfo returns the dependency package of ci, if ci is a
+// transitive capability of packageName reached through a package outside of
+// the own module. An error is returned for malformed capability infos.
+func relevantCapabilityInfo(ci *proto.CapabilityInfo, packageName, packagePrefix string) (string, bool, error) {
 	if ci.GetCapabilityType() != proto.CapabilityType_CAPABILITY_TYPE_TRANSITIVE {
-		return "", false
+		return "", false, nil
 	}
 
 	if len(ci.GetPath()) < 2 {
-		panic("for transitive capabilities, a min length of 2 is expected")
+		return "", false, fmt.Errorf("%s: transitive capability with path of length %d, expected at least 2", describeCapabilityInfo(ci), len(ci.GetPath()))
 	}
 
-	if extractPackagePath(*ci.GetPath()[0].Name) != packageName {
-		return "", false
+	pkg, err := packagePath(ci.GetPath()[0])
+	if err != nil {
+		return "", false, fmt.Errorf("%s: %w", describeCapabilityInfo(ci), err)
+	}
+	if pkg != packageName {
+		return "", false, nil
 	}
 
-	depPkg := extractPackagePath(*ci.GetPath()[1].Name)
+	depPkg, err := packagePath(ci.GetPath()[1])
+	if err != nil {
+		return "", false, fmt.Errorf("%s: %w", describeCapabilityInfo(ci), err)
+	}
 	if strings.HasPrefix(depPkg, packagePrefix) {
 		// if we call an other package of our own module, we ignore this call here
 		// TODO: make this behavior configurable
-		return "", false
+		return "", false, nil
 	}
 
 	if len(depPkg) == 0 {
-		return "", false
+		return "", false, nil
 	}
 
-	return depPkg, true
+	return depPkg, true, nil
 }
 
-func extractPackagePath(pathName string) string {
-	if strings.HasPrefix(pathName, "(") { // method
-		pathName = pathName[1:strings.LastIndex(pathName, ")")]
+// describeCapabilityInfo describes ci for error messages by its capability
+// and its path.
+func describeCapabilityInfo(ci *proto.CapabilityInfo) string {
+	names := make([]string, 0, len(ci.GetPath()))
+	for _, fn := range ci.GetPath() {
+		names = append(names, fn.GetName())
 	}
-	pathName = strings.TrimLeft(pathName, "*") // pointer receiver
-	return pathName[:strings.LastIndex(pathName, ".")]
+	return fmt.Sprintf("capability info %s with path %q", ci.GetCapability(), strings.Join(names, " "))
 }
 
-func findPos(pass *analysis.Pass, pkg string) token.Pos {
-	for _, file := range pass.Files {
-		if strings.HasSuffix(pass.Fset.File(file.Pos()).Name(), "_test.go") {
-			continue
-		}
-		for _, i := range file.Imports {
-			if pkg == strings.Trim(i.Path.Value, `"`) {
-				return i.Pos()
-			}
-		}
+// sortedCapabilityNames returns the capabilities of caps sorted by name.
+func sortedCapabilityNames(caps map[proto.Capability]struct{}) []proto.Capability {
+	sorted := make([]proto.Capability, 0, len(caps))
+	for c := range caps {
+		sorted = append(sorted, c)
 	}
-
-	return token.NoPos
+	sort.Slice(sorted, func(i, j int) bool {
+		return sorted[i].String() < sorted[j].String()
+	})
+	return sorted
 }
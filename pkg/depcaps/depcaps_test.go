@@ -1,13 +1,19 @@
 package depcaps_test
 
 import (
+	"context"
+	"fmt"
 	"os"
 	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
 	"testing"
 
 	// unused import of "github.com/google/uuid" to workaround GOPROXY=no in
 	// analysistest. This caches the module in CI before analysistest is executed.
 	_ "github.com/google/uuid"
+	"golang.org/x/tools/go/analysis"
 	"golang.org/x/tools/go/analysis/analysistest"
 
 	"github.com/breml/depcaps/pkg/depcaps"
@@ -94,6 +100,12 @@ func TestAll(t *testing.T) {
 			testdataDir: "alltest",
 			packages:    []string{"./capslockfile/..."},
 		},
+		{
+			name:           "directive",
+			linterSettings: nil,
+			testdataDir:    "alltest",
+			packages:       []string{"./directive/..."},
+		},
 	}
 
 	wd, err := os.Getwd()
@@ -103,6 +115,10 @@ func TestAll(t *testing.T) {
 
 	testdata := filepath.Join(filepath.Dir(filepath.Dir(wd)), "testdata")
 
+	// The cache is shared between the test cases, such that the cached
+	// capabilities of the dependencies are used from the second test case on.
+	cacheDir := t.TempDir()
+
 	for _, tc := range tt {
 		t.Run(tc.name, func(t *testing.T) {
 			testCaseDir := filepath.Join(testdata, "src", tc.testdataDir)
@@ -119,12 +135,501 @@ func TestAll(t *testing.T) {
 
 			tc.linterSettings = osSpecificLinterSettings(tc.linterSettings)
 
-			depcapsLinter := depcaps.New(tc.linterSettings).WithArgs([]string{"./..."})
-			if tc.linterSettings != nil {
-				depcapsLinter = depcapsLinter.WithBaselineFile(tc.linterSettings.CapslockBaselineFile)
-			}
+			depcapsLinter := newLinter(t, tc.linterSettings, depcaps.WithCacheDir(cacheDir))
 
 			analysistest.Run(t, testCaseDir, depcapsLinter.AsAnalyzer(false), tc.packages...)
 		})
 	}
 }
+
+func TestDiagnosticsSorted(t *testing.T) {
+	testCaseDir := chdirTestdata(t, "alltest")
+
+	results := analysistest.Run(t, testCaseDir, newLinter(t, nil, depcaps.WithCacheDir(t.TempDir())).AsAnalyzer(false), "./simple/function")
+	for _, result := range results {
+		var messages []string
+		for _, diag := range result.Diagnostics {
+			messages = append(messages, diag.Message)
+		}
+		if !sort.StringsAreSorted(messages) {
+			t.Fatalf("expected diagnostics of package %s to be sorted, got: %v", result.Pass.Pkg.Path(), messages)
+		}
+	}
+}
+
+func TestDiagnosticsRelated(t *testing.T) {
+	testCaseDir := chdirTestdata(t, "alltest")
+
+	results := analysistest.Run(t, testCaseDir, newLinter(t, nil, depcaps.WithCacheDir(t.TempDir())).AsAnalyzer(false), "./simple/function")
+	for _, result := range results {
+		for _, diag := range result.Diagnostics {
+			if !strings.HasSuffix(diag.Message, "CAPABILITY_NETWORK") {
+				continue
+			}
+			if len(diag.Related) == 0 {
+				t.Fatalf("expected related information for diagnostic %q", diag.Message)
+			}
+
+			posn := result.Pass.Fset.Position(diag.Related[0].Pos)
+			if filepath.Base(posn.Filename) != "func_go122.go" || posn.Line != 12 {
+				t.Fatalf("expected first related information at the call site func_go122.go:12, got: %s", posn)
+			}
+			if diag.Related[0].Message != "alltest/simple/function.Call calls github.com/google/uuid.GetTime" {
+				t.Fatalf("unexpected message of related information: %q", diag.Related[0].Message)
+			}
+			return
+		}
+	}
+
+	t.Fatalf("expected diagnostic for CAPABILITY_NETWORK")
+}
+
+// newLinter returns a new Linter and fails the test on error.
+func newLinter(t *testing.T, settings *depcaps.LinterSettings, opts ...depcaps.Option) *depcaps.Linter {
+	t.Helper()
+
+	l, err := depcaps.New(settings, opts...)
+	if err != nil {
+		t.Fatalf("Failed to create linter: %s", err)
+	}
+	return l
+}
+
+// chdirTestdata changes the working directory to the given directory in
+// testdata/src for the duration of the test.
+func chdirTestdata(t *testing.T, dir string) string {
+	t.Helper()
+
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatalf("Failed to get wd: %s", err)
+	}
+
+	testCaseDir := filepath.Join(filepath.Dir(filepath.Dir(wd)), "testdata", "src", dir)
+	err = os.Chdir(testCaseDir)
+	if err != nil {
+		t.Fatalf("Failed to change wd: %s", err)
+	}
+	t.Cleanup(func() {
+		err := os.Chdir(wd)
+		if err != nil {
+			t.Fatalf("Failed to return to wd: %s", err)
+		}
+	})
+
+	return testCaseDir
+}
+
+func TestSuggestedFixes(t *testing.T) {
+	tt := []struct {
+		name   string
+		config string
+		// capability selects the diagnostic, whose fix is applied. The fixes
+		// of all the diagnostics are applied, if it is empty.
+		capability string
+		want       string
+	}{
+		{
+			name:       "empty config",
+			config:     "{}\n",
+			capability: "CAPABILITY_NETWORK",
+			want: `{
+  "PackageAllowedCapabilities": {
+    "github.com/google/uuid": {
+      "CAPABILITY_NETWORK": true,
+      "CAPABILITY_READ_SYSTEM_STATE": true,
+      "CAPABILITY_REFLECT": true
+    }
+  }
+}
+`,
+		},
+		{
+			name: "global allow only",
+			config: `{
+	"GlobalAllowedCapabilities": {
+		"CAPABILITY_EXEC": true
+	}
+}
+`,
+			capability: "CAPABILITY_NETWORK",
+			want: `{
+	"GlobalAllowedCapabilities": {
+		"CAPABILITY_EXEC": true
+	},
+	"PackageAllowedCapabilities": {
+		"github.com/google/uuid": {
+			"CAPABILITY_NETWORK": true,
+			"CAPABILITY_READ_SYSTEM_STATE": true,
+			"CAPABILITY_REFLECT": true
+		}
+	}
+}
+`,
+		},
+		{
+			name: "other packages",
+			config: `{
+  "PackageAllowedCapabilities": {
+    "github.com/a/a": {
+      "CAPABILITY_FILES": true
+    },
+    "github.com/z/z": {
+      "CAPABILITY_FILES": true
+    }
+  }
+}
+`,
+			capability: "CAPABILITY_REFLECT",
+			want: `{
+  "PackageAllowedCapabilities": {
+    "github.com/a/a": {
+      "CAPABILITY_FILES": true
+    },
+    "github.com/google/uuid": {
+      "CAPABILITY_NETWORK": true,
+      "CAPABILITY_READ_SYSTEM_STATE": true,
+      "CAPABILITY_REFLECT": true
+    },
+    "github.com/z/z": {
+      "CAPABILITY_FILES": true
+    }
+  }
+}
+`,
+		},
+		{
+			name: "empty package",
+			config: `{
+  "PackageAllowedCapabilities": {
+    "github.com/google/uuid": {}
+  }
+}
+`,
+			capability: "CAPABILITY_NETWORK",
+			want: `{
+  "PackageAllowedCapabilities": {
+    "github.com/google/uuid": {
+      "CAPABILITY_NETWORK": true,
+      "CAPABILITY_READ_SYSTEM_STATE": true,
+      "CAPABILITY_REFLECT": true
+    }
+  }
+}
+`,
+		},
+		{
+			name: "package present",
+			config: `{
+  "PackageAllowedCapabilities": {
+    "github.com/google/uuid": {
+      "CAPABILITY_EXEC": true
+    }
+  }
+}
+`,
+			want: `{
+  "PackageAllowedCapabilities": {
+    "github.com/google/uuid": {
+      "CAPABILITY_EXEC": true,
+      "CAPABILITY_NETWORK": true,
+      "CAPABILITY_READ_SYSTEM_STATE": true,
+      "CAPABILITY_REFLECT": true
+    }
+  }
+}
+`,
+		},
+		{
+			name: "package present in the middle",
+			config: `{
+  "PackageAllowedCapabilities": {
+    "github.com/google/uuid": {
+      "CAPABILITY_EXEC": true,
+      "CAPABILITY_OPERATING_SYSTEM": true
+    }
+  }
+}
+`,
+			want: `{
+  "PackageAllowedCapabilities": {
+    "github.com/google/uuid": {
+      "CAPABILITY_EXEC": true,
+      "CAPABILITY_NETWORK": true,
+      "CAPABILITY_OPERATING_SYSTEM": true,
+      "CAPABILITY_READ_SYSTEM_STATE": true,
+      "CAPABILITY_REFLECT": true
+    }
+  }
+}
+`,
+		},
+		{
+			// The fixes of all the findings of the package are identical and
+			// are applied together.
+			name:   "empty config all fixes",
+			config: "{}\n",
+			want: `{
+  "PackageAllowedCapabilities": {
+    "github.com/google/uuid": {
+      "CAPABILITY_NETWORK": true,
+      "CAPABILITY_READ_SYSTEM_STATE": true,
+      "CAPABILITY_REFLECT": true
+    }
+  }
+}
+`,
+		},
+	}
+
+	testCaseDir := chdirTestdata(t, "alltest")
+	cacheDir := t.TempDir()
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			configFile := writeConfig(t, tc.config)
+
+			settings := &depcaps.LinterSettings{}
+			err := settings.Set(configFile)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			results := analysistest.Run(t, testCaseDir, newLinter(t, settings, depcaps.WithCacheDir(cacheDir)).AsAnalyzer(false), "./simple/function")
+
+			// The package is analyzed with and without its tests, the fixes of
+			// the findings of both are applied together. Capabilities, which
+			// are not part of the fact of the dependency, have no fix.
+			var edits []edit
+			for _, result := range results {
+				for _, diag := range result.Diagnostics {
+					if tc.capability != "" && diag.Category != tc.capability || len(diag.SuggestedFixes) == 0 {
+						continue
+					}
+					edits = append(edits, fixEdits(t, result, diag, configFile)...)
+				}
+			}
+			if len(edits) == 0 {
+				t.Fatalf("expected suggested fix for %q", tc.capability)
+			}
+
+			got, err := applyEdits(tc.config, edits)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if got != tc.want {
+				t.Fatalf("unexpected config after applying the suggested fixes, want:\n%s\ngot:\n%s", tc.want, got)
+			}
+		})
+	}
+}
+
+func TestSuggestedFixesIdentical(t *testing.T) {
+	testCaseDir := chdirTestdata(t, "alltest")
+
+	configFile := writeConfig(t, "{}\n")
+	settings := &depcaps.LinterSettings{}
+	err := settings.Set(configFile)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	results := analysistest.Run(t, testCaseDir, newLinter(t, settings, depcaps.WithCacheDir(t.TempDir())).AsAnalyzer(false), "./simple/...")
+
+	// All the findings of the dependency package reported by several
+	// packages have the same fix. CAPABILITY_FILES is only gained through
+	// the types of the test of alltest/simple/function, which are not part
+	// of the fact of the dependency, so it has no fix.
+	var want []edit
+	packages := make(map[string]int)
+	for _, result := range results {
+		for _, diag := range result.Diagnostics {
+			if diag.Category == "CAPABILITY_FILES" {
+				if len(diag.SuggestedFixes) != 0 {
+					t.Fatalf("expected no suggested fix for %s, got: %+v", diag.Category, diag.SuggestedFixes)
+				}
+				continue
+			}
+			edits := fixEdits(t, result, diag, configFile)
+			if want != nil && !reflect.DeepEqual(edits, want) {
+				t.Fatalf("expected identical fixes, got: %+v and %+v", want, edits)
+			}
+			want = edits
+			packages[diag.Category]++
+		}
+	}
+	if packages["CAPABILITY_NETWORK"] < 2 {
+		t.Fatalf("expected CAPABILITY_NETWORK to be reported by several packages, got: %v", packages)
+	}
+}
+
+func TestFix(t *testing.T) {
+	tt := []struct {
+		name   string
+		config string
+		want   string
+	}{
+		{
+			name:   "empty config",
+			config: "{}\n",
+			want: `{
+  "PackageAllowedCapabilities": {
+    "github.com/google/uuid": {
+      "CAPABILITY_FILES": true,
+      "CAPABILITY_NETWORK": true,
+      "CAPABILITY_REFLECT": true
+    }
+  }
+}
+`,
+		},
+		{
+			name:   "new package",
+			config: "{\n  \"PackageAllowedCapabilities\": {\n    \"github.com/z/z\": {}\n  }\n}\n",
+			want: `{
+  "PackageAllowedCapabilities": {
+    "github.com/google/uuid": {
+      "CAPABILITY_FILES": true,
+      "CAPABILITY_NETWORK": true,
+      "CAPABILITY_REFLECT": true
+    },
+    "github.com/z/z": {}
+  }
+}
+`,
+		},
+		{
+			name: "package present",
+			config: `{
+  "PackageAllowedCapabilities": {
+    "github.com/google/uuid": {
+      "CAPABILITY_EXEC": true,
+      "CAPABILITY_NETWORK": true
+    }
+  },
+  "PackageDeniedCapabilities": {
+    "github.com/google/uuid": {
+      "CAPABILITY_REFLECT": "warning"
+    }
+  }
+}
+`,
+			want: `{
+  "PackageAllowedCapabilities": {
+    "github.com/google/uuid": {
+      "CAPABILITY_EXEC": true,
+      "CAPABILITY_FILES": true,
+      "CAPABILITY_NETWORK": true
+    }
+  },
+  "PackageDeniedCapabilities": {
+    "github.com/google/uuid": {
+      "CAPABILITY_REFLECT": "warning"
+    }
+  }
+}
+`,
+		},
+	}
+
+	testCaseDir := chdirTestdata(t, "alltest")
+	cacheDir := t.TempDir()
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			configFile := writeConfig(t, tc.config)
+
+			settings := &depcaps.LinterSettings{}
+			err := settings.Set(configFile)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			l := newLinter(t, settings, depcaps.WithCacheDir(cacheDir))
+
+			findings, err := l.Analyze(context.Background(), testCaseDir, "./simple/...")
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			err = l.Fix(findings)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			got, err := os.ReadFile(configFile)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if string(got) != tc.want {
+				t.Fatalf("unexpected config after fixing, want:\n%s\ngot:\n%s", tc.want, got)
+			}
+		})
+	}
+}
+
+// writeConfig writes the config file with content to a temporary directory
+// and returns its name.
+func writeConfig(t *testing.T, content string) string {
+	t.Helper()
+
+	configFile := filepath.Join(t.TempDir(), "config.json")
+	err := os.WriteFile(configFile, []byte(content), 0o600)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	return configFile
+}
+
+type edit struct {
+	start, end int
+	text       string
+}
+
+// fixEdits returns the edits of the single suggested fix of diag, which must
+// edit the config file.
+func fixEdits(t *testing.T, result *analysistest.Result, diag analysis.Diagnostic, configFile string) []edit {
+	t.Helper()
+
+	if len(diag.SuggestedFixes) != 1 {
+		t.Fatalf("expected 1 suggested fix for diagnostic %q, got: %d", diag.Message, len(diag.SuggestedFixes))
+	}
+
+	var edits []edit
+	for _, e := range diag.SuggestedFixes[0].TextEdits {
+		file := result.Pass.Fset.File(e.Pos)
+		if file == nil || file.Name() != configFile {
+			t.Fatalf("expected suggested fix for %s, got: %v", configFile, file)
+		}
+		edits = append(edits, edit{start: file.Offset(e.Pos), end: file.Offset(e.End), text: string(e.NewText)})
+	}
+	return edits
+}
+
+// applyEdits applies the edits to content the same way as the analysis
+// drivers do: identical edits are applied only once and overlapping edits are
+// a conflict.
+func applyEdits(content string, edits []edit) (string, error) {
+	sort.SliceStable(edits, func(i, j int) bool {
+		if edits[i].start != edits[j].start {
+			return edits[i].start < edits[j].start
+		}
+		return edits[i].end < edits[j].end
+	})
+
+	var b strings.Builder
+	offset := 0
+	for i, e := range edits {
+		if i > 0 && e == edits[i-1] {
+			continue
+		}
+		if e.start < offset {
+			return "", fmt.Errorf("conflicting edits at offset %d", e.start)
+		}
+		b.WriteString(content[offset:e.start])
+		b.WriteString(e.text)
+		offset = e.end
+	}
+	b.WriteString(content[offset:])
+
+	return b.String(), nil
+}
@@ -11,7 +11,9 @@
 package depcaps
 
 import (
+	"fmt"
 	"sort"
+	"strings"
 
 	"github.com/google/capslock/proto"
 )
@@ -19,15 +21,76 @@ import (
 type (
 	capabilitySet   map[proto.Capability]*proto.CapabilityInfo
 	capabilitiesMap map[string]capabilitySet
+
+	// capabilityIndex maps the importing package to the capabilities of its
+	// dependencies. Once built, the index is never modified and can therefore
+	// be read concurrently.
+	capabilityIndex map[string]capabilitiesMap
 )
 
+// newCapabilityIndex takes a CapabilityInfoList and returns an index from
+// importing package, dependency package and capability to a pointer to the
+// corresponding entry in the input. An error naming the offending entry is
+// returned for malformed entries.
+func newCapabilityIndex(cil *proto.CapabilityInfoList) (capabilityIndex, error) {
+	idx := make(capabilityIndex)
+	for i, ci := range cil.GetCapabilityInfo() {
+		if ci.GetCapabilityType() != proto.CapabilityType_CAPABILITY_TYPE_TRANSITIVE {
+			continue
+		}
+		if len(ci.GetPath()) < 2 {
+			return nil, fmt.Errorf("capabilityInfo[%d]: %s: transitive capability with path of length %d, expected at least 2", i, describeCapabilityInfo(ci), len(ci.GetPath()))
+		}
+
+		packageName, err := packagePath(ci.GetPath()[0])
+		if err != nil {
+			return nil, fmt.Errorf("capabilityInfo[%d]: %w", i, err)
+		}
+		depPkg, err := packagePath(ci.GetPath()[1])
+		if err != nil {
+			return nil, fmt.Errorf("capabilityInfo[%d]: %w", i, err)
+		}
+		if len(depPkg) == 0 {
+			continue
+		}
+
+		m := idx[packageName]
+		if m == nil {
+			m = make(capabilitiesMap)
+			idx[packageName] = m
+		}
+		capmap := m[depPkg]
+		if capmap == nil {
+			capmap = make(capabilitySet)
+			m[depPkg] = capmap
+		}
+		capmap[ci.GetCapability()] = ci
+	}
+	return idx, nil
+}
+
+// dependencies returns the capabilities of the dependencies of packageName,
+// excluding the packages with packagePrefix.
+func (idx capabilityIndex) dependencies(packageName, packagePrefix string) capabilitiesMap {
+	m := make(capabilitiesMap, len(idx[packageName]))
+	for depPkg, capmap := range idx[packageName] {
+		if strings.HasPrefix(depPkg, packagePrefix) {
+			// if we call an other package of our own module, we ignore this call here
+			continue
+		}
+		m[depPkg] = capmap
+	}
+	return m
+}
+
 // populateMap takes a CapabilityInfoList and returns a map from package
-// directory and capability to a pointer to the corresponding entry in the
-// input.
+// directory and capability to a pointer to the first corresponding entry in
+// the input.
 func populateMap(cil *proto.CapabilityInfoList, packageName, packagePrefix string) capabilitiesMap {
 	m := make(capabilitiesMap)
 	for _, ci := range cil.GetCapabilityInfo() {
-		depPkg, skip := relevantCapabilityInfo(ci, packageName, packagePrefix)
+		// The capability infos of the summary are never malformed.
+		depPkg, skip, _ := relevantCapabilityInfo(ci, packageName, packagePrefix)
 		if !skip {
 			continue
 		}
@@ -37,13 +100,15 @@ func populateMap(cil *proto.CapabilityInfoList, packageName, packagePrefix strin
 			capmap = make(capabilitySet)
 			m[depPkg] = capmap
 		}
-		capmap[ci.GetCapability()] = ci
+		if _, ok := capmap[ci.GetCapability()]; !ok {
+			capmap[ci.GetCapability()] = ci
+		}
 	}
 	return m
 }
 
-func diffCapabilityInfoLists(baseline, current *proto.CapabilityInfoList, packageName, packagePrefix string) map[string]map[proto.Capability]struct{} {
-	baselineMap := populateMap(baseline, packageName, packagePrefix)
+func diffCapabilityInfoLists(baseline capabilityIndex, current *proto.CapabilityInfoList, packageName, packagePrefix string) map[string]map[proto.Capability]struct{} {
+	baselineMap := baseline.dependencies(packageName, packagePrefix)
 	currentMap := populateMap(current, packageName, packagePrefix)
 
 	var packages []string
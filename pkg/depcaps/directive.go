@@ -0,0 +1,156 @@
+package depcaps
+
+import (
+	"errors"
+	"fmt"
+	"go/ast"
+	"go/token"
+	"strconv"
+	"strings"
+
+	"github.com/google/capslock/proto"
+	"golang.org/x/tools/go/analysis"
+)
+
+const (
+	directivePrefix = "//depcaps:"
+	allowDirective  = directivePrefix + "allow"
+)
+
+// directive is a //depcaps:allow directive on an import spec, which allows
+// capabilities of the imported package for the importing package only:
+//
+//	import "github.com/google/uuid" //depcaps:allow CAPABILITY_NETWORK reason="time-based UUIDs"
+type directive struct {
+	pos          token.Pos
+	pkg          string
+	capabilities []proto.Capability
+	reason       string
+
+	// used is true, if the directive allowed at least one capability.
+	used bool
+}
+
+// importDirectives returns the allow directives on the import specs of the
+// package analyzed by pass. Malformed directives are reported and omitted,
+// their findings are returned as well.
+func importDirectives(pass *analysis.Pass) ([]*directive, []Finding) {
+	var directives []*directive
+	var problems []Finding
+	for _, file := range pass.Files {
+		for _, spec := range file.Imports {
+			pkg, err := strconv.Unquote(spec.Path.Value)
+			if err != nil {
+				continue
+			}
+			for _, group := range []*ast.CommentGroup{spec.Doc, spec.Comment} {
+				if group == nil {
+					continue
+				}
+				for _, c := range group.List {
+					if !strings.HasPrefix(c.Text, directivePrefix) {
+						continue
+					}
+					caps, reason, err := parseDirective(c.Text)
+					if err != nil {
+						problems = append(problems, reportProblem(pass, c.Pos(), "directive", fmt.Sprintf("malformed depcaps directive: %v", err)))
+						continue
+					}
+					directives = append(directives, &directive{
+						pos:          c.Pos(),
+						pkg:          pkg,
+						capabilities: caps,
+						reason:       reason,
+					})
+				}
+			}
+		}
+	}
+	return directives, problems
+}
+
+// parseDirective parses a directive of the form
+//
+//	//depcaps:allow CAPABILITY[,CAPABILITY...] reason="..."
+//
+// The reason is mandatory and must not be empty.
+func parseDirective(text string) ([]proto.Capability, string, error) {
+	rest, ok := strings.CutPrefix(text, allowDirective)
+	if !ok || (rest != "" && rest[0] != ' ' && rest[0] != '\t') {
+		verb, _, _ := strings.Cut(strings.TrimPrefix(text, directivePrefix), " ")
+		return nil, "", fmt.Errorf("unknown directive %q, expected allow", verb)
+	}
+
+	list, rest, _ := strings.Cut(strings.TrimSpace(rest), " ")
+	if list == "" {
+		return nil, "", errors.New(`expected //depcaps:allow CAPABILITY[,CAPABILITY...] reason="..."`)
+	}
+	var caps []proto.Capability
+	for _, name := range strings.Split(list, ",") {
+		c, ok := proto.Capability_value[name]
+		if !ok {
+			return nil, "", fmt.Errorf("unknown capability %q", name)
+		}
+		caps = append(caps, proto.Capability(c))
+	}
+
+	rest = strings.TrimSpace(rest)
+	value, ok := strings.CutPrefix(rest, "reason=")
+	if !ok {
+		if rest == "" {
+			return nil, "", errors.New("reason is required")
+		}
+		return nil, "", fmt.Errorf(`expected reason="...", got %q`, rest)
+	}
+	quoted, err := strconv.QuotedPrefix(value)
+	if err != nil {
+		return nil, "", fmt.Errorf("reason must be a quoted string, got %s", value)
+	}
+	if trailing := strings.TrimSpace(value[len(quoted):]); trailing != "" {
+		return nil, "", fmt.Errorf("unexpected %q after reason", trailing)
+	}
+	reason, err := strconv.Unquote(quoted)
+	if err != nil {
+		return nil, "", fmt.Errorf("reason must be a quoted string, got %s", quoted)
+	}
+	if strings.TrimSpace(reason) == "" {
+		return nil, "", errors.New("reason is required")
+	}
+
+	return caps, reason, nil
+}
+
+// allowedByDirective reports, if the capability c of depPkg is allowed by one
+// of the directives. All the matching directives are marked as used.
+func allowedByDirective(directives []*directive, depPkg string, c proto.Capability) bool {
+	allowed := false
+	for _, d := range directives {
+		if d.pkg != depPkg {
+			continue
+		}
+		for _, dc := range d.capabilities {
+			if dc == c {
+				d.used = true
+				allowed = true
+			}
+		}
+	}
+	return allowed
+}
+
+// reportUnusedDirectives reports the directives, which did not allow any
+// capability, and returns their findings.
+func reportUnusedDirectives(pass *analysis.Pass, directives []*directive) []Finding {
+	var problems []Finding
+	for _, d := range directives {
+		if d.used {
+			continue
+		}
+		names := make([]string, 0, len(d.capabilities))
+		for _, c := range d.capabilities {
+			names = append(names, c.String())
+		}
+		problems = append(problems, reportProblem(pass, d.pos, "directive", fmt.Sprintf("unused depcaps directive: package %s has no not allowed capability %s", d.pkg, strings.Join(names, ", "))))
+	}
+	return problems
+}
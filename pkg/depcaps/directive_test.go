@@ -0,0 +1,69 @@
+package depcaps_test
+
+import (
+	"bytes"
+	"context"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/breml/depcaps/pkg/depcaps"
+)
+
+func TestDirectiveLocal(t *testing.T) {
+	testCaseDir := chdirTestdata(t, "alltest")
+
+	findings, err := newLinter(t, nil, depcaps.WithCacheDir(t.TempDir())).Analyze(context.Background(), testCaseDir, "./allow/...", "./directive/...")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	decisions := make(map[string]depcaps.Decision)
+	for _, f := range findings {
+		if f.Capability.String() == "CAPABILITY_NETWORK" {
+			decisions[f.Package] = f.Decision
+		}
+	}
+
+	// The directive only allows the capability for the importing package.
+	want := map[string]depcaps.Decision{
+		"alltest/allow":     depcaps.Violation,
+		"alltest/directive": depcaps.AllowedDirective,
+	}
+	for pkg, decision := range want {
+		if decisions[pkg] != decision {
+			t.Fatalf("expected decision %s for package %s, got: %v", decision, pkg, decisions)
+		}
+	}
+
+	// The malformed and unused directives are problems of the analysis.
+	var problems []string
+	for _, f := range findings {
+		if f.Problem == "" {
+			continue
+		}
+		if f.Package != "alltest/directive" || filepath.Base(f.Position.Filename) != "malformed.go" || f.Decision != depcaps.Violation || f.Severity != depcaps.SeverityError {
+			t.Fatalf("expected problem in malformed.go of alltest/directive with severity error, got: %+v", f)
+		}
+		problems = append(problems, f.Problem)
+	}
+	wantProblems := []string{
+		"unused depcaps directive: package fmt has no not allowed capability CAPABILITY_FILES",
+		"malformed depcaps directive: reason is required",
+		`malformed depcaps directive: unknown capability "CAPABILITY_STRINGS"`,
+		`malformed depcaps directive: unknown directive "deny", expected allow`,
+	}
+	if strings.Join(problems, "\n") != strings.Join(wantProblems, "\n") {
+		t.Fatalf("expected problems:\n%s\ngot:\n%s", strings.Join(wantProblems, "\n"), strings.Join(problems, "\n"))
+	}
+
+	var buf bytes.Buffer
+	err = depcaps.WriteText(&buf, findings)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	wantText := "malformed.go:5:112: error: malformed depcaps directive: reason is required\n"
+	if !strings.Contains(buf.String(), wantText) {
+		t.Fatalf("expected output to contain %q, got:\n%s", wantText, buf.String())
+	}
+}
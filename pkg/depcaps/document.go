@@ -0,0 +1,211 @@
+package depcaps
+
+import (
+	"fmt"
+	"html/template"
+	"io"
+	"strings"
+)
+
+// documentRow is a row of the capability table of the Markdown and HTML
+// documents.
+type documentRow struct {
+	Module     string
+	Version    string
+	Package    string
+	Capability string
+	Violation  bool
+	Decision   string
+	Severity   string
+	ReachedBy  []string
+	CallPath   []string
+}
+
+// documentRows returns the rows of the capability table of r, one row for
+// every capability of a package, one for every unapproved module and one for
+// every problem of the analysis. The call path of the first reaching package
+// serves as sample.
+func (r *Report) documentRows() []documentRow {
+	var rows []documentRow
+	for _, p := range r.Problems {
+		rows = append(rows, documentRow{
+			Violation: true,
+			Decision:  p.Message,
+			Severity:  p.Severity.String(),
+			ReachedBy: []string{p.Package},
+		})
+	}
+	for _, mod := range r.Modules {
+		if mod.Unapproved {
+			rows = append(rows, documentRow{
+				Module:    mod.Path,
+				Version:   mod.Version,
+				Violation: true,
+				Decision:  "module not approved",
+				Severity:  SeverityError.String(),
+			})
+		}
+		for _, pkg := range mod.Packages {
+			for _, c := range pkg.Capabilities {
+				row := documentRow{
+					Module:     mod.Path,
+					Version:    mod.Version,
+					Package:    pkg.Path,
+					Capability: c.Capability,
+					Violation:  c.Decision == Violation,
+					Decision:   decisionText(c.Decision, pkg.Path),
+				}
+				if c.Severity != SeverityNone {
+					row.Severity = c.Severity.String()
+				}
+				for _, reach := range c.ReachedBy {
+					row.ReachedBy = append(row.ReachedBy, reach.Package)
+				}
+				if len(c.ReachedBy) > 0 {
+					row.CallPath = c.ReachedBy[0].CallPath
+				}
+				rows = append(rows, row)
+			}
+		}
+	}
+	return rows
+}
+
+// decisionText returns the decision for the capability of pkg in words.
+func decisionText(d Decision, pkg string) string {
+	switch d {
+	case AllowedGlobal:
+		return "allowed by GlobalAllowedCapabilities"
+	case AllowedPackage:
+		return fmt.Sprintf("allowed by PackageAllowedCapabilities of %s", pkg)
+	case AllowedBaseline:
+		return "covered by baseline"
+	case AllowedDirective:
+		return "allowed by //depcaps:allow directive"
+	default:
+		return "violation"
+	}
+}
+
+// summary returns the number of modules, capabilities and violations of r.
+// The problems of the analysis are violations as well.
+func (r *Report) summary() (modules, capabilities, violations int) {
+	violations = len(r.Problems)
+	for _, mod := range r.Modules {
+		modules++
+		if mod.Unapproved {
+			violations++
+		}
+		for _, pkg := range mod.Packages {
+			for _, c := range pkg.Capabilities {
+				capabilities++
+				if c.Decision == Violation {
+					violations++
+				}
+			}
+		}
+	}
+	return modules, capabilities, violations
+}
+
+// WriteMarkdown writes the report as Markdown document to w.
+func (r *Report) WriteMarkdown(w io.Writer) error {
+	modules, capabilities, violations := r.summary()
+
+	var b strings.Builder
+	b.WriteString("# Capabilities of dependencies\n\n")
+	fmt.Fprintf(&b, "Modules: %d, capabilities: %d, violations: %d\n\n", modules, capabilities, violations)
+	b.WriteString("| Module | Package | Capability | Decision | Severity | Reached by | Sample call path |\n")
+	b.WriteString("| --- | --- | --- | --- | --- | --- | --- |\n")
+
+	for _, row := range r.documentRows() {
+		module := row.Module
+		if row.Version != "" {
+			module += "@" + row.Version
+		}
+		decision := row.Decision
+		if row.Violation {
+			decision = "**" + decision + "**"
+		}
+
+		callPath := make([]string, 0, len(row.CallPath))
+		for _, fn := range row.CallPath {
+			callPath = append(callPath, "`"+markdownEscape(fn)+"`")
+		}
+
+		fmt.Fprintf(&b, "| %s | %s | %s | %s | %s | %s | %s |\n",
+			markdownEscape(module),
+			markdownEscape(row.Package),
+			row.Capability,
+			decision,
+			row.Severity,
+			markdownEscape(strings.Join(row.ReachedBy, ", ")),
+			strings.Join(callPath, " → "),
+		)
+	}
+
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// markdownEscape escapes the characters, which would break a Markdown table.
+func markdownEscape(s string) string {
+	return strings.NewReplacer("|", `\|`, "\n", " ").Replace(s)
+}
+
+var htmlTemplate = template.Must(template.New("report").Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<title>Capabilities of dependencies</title>
+<style>
+body { font-family: sans-serif; margin: 2em; }
+table { border-collapse: collapse; }
+th, td { border: 1px solid #ccc; padding: 0.3em 0.6em; text-align: left; vertical-align: top; }
+th { background: #f0f0f0; }
+tr.violation td.decision { color: #b00; font-weight: bold; }
+ol { margin: 0; padding-left: 1.5em; }
+code { font-size: 0.9em; }
+</style>
+</head>
+<body>
+<h1>Capabilities of dependencies</h1>
+<p>Modules: {{ .Modules }}, capabilities: {{ .Capabilities }}, violations: {{ .Violations }}</p>
+<table>
+<thead>
+<tr><th>Module</th><th>Package</th><th>Capability</th><th>Decision</th><th>Severity</th><th>Reached by</th><th>Sample call path</th></tr>
+</thead>
+<tbody>
+{{- range .Rows }}
+<tr{{ if .Violation }} class="violation"{{ end }}>
+<td>{{ .Module }}{{ if .Version }}@{{ .Version }}{{ end }}</td>
+<td>{{ .Package }}</td>
+<td>{{ .Capability }}</td>
+<td class="decision">{{ .Decision }}</td>
+<td>{{ .Severity }}</td>
+<td>{{ range $i, $p := .ReachedBy }}{{ if $i }}<br>{{ end }}{{ $p }}{{ end }}</td>
+<td><ol>{{ range .CallPath }}<li><code>{{ . }}</code></li>{{ end }}</ol></td>
+</tr>
+{{- end }}
+</tbody>
+</table>
+</body>
+</html>
+`))
+
+// WriteHTML writes the report as self-contained HTML document to w.
+func (r *Report) WriteHTML(w io.Writer) error {
+	modules, capabilities, violations := r.summary()
+
+	return htmlTemplate.Execute(w, struct {
+		Modules      int
+		Capabilities int
+		Violations   int
+		Rows         []documentRow
+	}{
+		Modules:      modules,
+		Capabilities: capabilities,
+		Violations:   violations,
+		Rows:         r.documentRows(),
+	})
+}
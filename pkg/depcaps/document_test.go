@@ -0,0 +1,97 @@
+package depcaps_test
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/breml/depcaps/pkg/depcaps"
+)
+
+func testReport() *depcaps.Report {
+	return &depcaps.Report{
+		Version: depcaps.ReportVersion,
+		Modules: []depcaps.ModuleReport{
+			{
+				Path:    "github.com/google/uuid",
+				Version: "v1.3.1",
+				Packages: []depcaps.PackageReport{
+					{
+						Path: "github.com/google/uuid",
+						Capabilities: []depcaps.CapabilityReport{
+							{
+								Capability: "CAPABILITY_NETWORK",
+								Decision:   depcaps.Violation,
+								Severity:   depcaps.SeverityWarning,
+								ReachedBy: []depcaps.ReachReport{
+									{
+										Package:  "example.com/app",
+										Decision: depcaps.Violation,
+										Severity: depcaps.SeverityWarning,
+										CallPath: []string{"example.com/app.main", "github.com/google/uuid.GetTime"},
+									},
+								},
+							},
+							{
+								Capability: "CAPABILITY_REFLECT",
+								Decision:   depcaps.AllowedPackage,
+								ReachedBy: []depcaps.ReachReport{
+									{
+										Package:  "example.com/app|pipe",
+										Decision: depcaps.AllowedPackage,
+										CallPath: []string{"example.com/app.main", "(*github.com/google/uuid.NullUUID).UnmarshalJSON"},
+									},
+								},
+							},
+						},
+					},
+				},
+			},
+		},
+	}
+}
+
+func TestWriteMarkdown(t *testing.T) {
+	var buf bytes.Buffer
+	err := testReport().WriteMarkdown(&buf)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	got := buf.String()
+
+	for _, want := range []string{
+		"Modules: 1, capabilities: 2, violations: 1",
+		"| github.com/google/uuid@v1.3.1 | github.com/google/uuid | CAPABILITY_NETWORK | **violation** | warning | example.com/app | `example.com/app.main` → `github.com/google/uuid.GetTime` |",
+		"| CAPABILITY_REFLECT | allowed by PackageAllowedCapabilities of github.com/google/uuid |  | example.com/app\\|pipe |",
+	} {
+		if !strings.Contains(got, want) {
+			t.Fatalf("expected Markdown to contain %q, got:\n%s", want, got)
+		}
+	}
+}
+
+func TestWriteHTML(t *testing.T) {
+	var buf bytes.Buffer
+	err := testReport().WriteHTML(&buf)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	got := buf.String()
+
+	for _, want := range []string{
+		"<!DOCTYPE html>",
+		"<style>",
+		`<tr class="violation">`,
+		"<td>warning</td>",
+		"<td>github.com/google/uuid@v1.3.1</td>",
+		"<li><code>(*github.com/google/uuid.NullUUID).UnmarshalJSON</code></li>",
+		"allowed by PackageAllowedCapabilities of github.com/google/uuid",
+	} {
+		if !strings.Contains(got, want) {
+			t.Fatalf("expected HTML to contain %q, got:\n%s", want, got)
+		}
+	}
+	if strings.Contains(got, "<script") || strings.Contains(got, "<link") {
+		t.Fatalf("expected self-contained HTML without external resources, got:\n%s", got)
+	}
+}
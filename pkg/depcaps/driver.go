@@ -0,0 +1,382 @@
+package depcaps
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"go/types"
+	"os"
+	"path/filepath"
+	"reflect"
+	"runtime"
+	"sort"
+	"sync"
+
+	"golang.org/x/tools/go/analysis"
+	"golang.org/x/tools/go/packages"
+)
+
+// Analyze loads the packages matching patterns in dir and returns the
+// findings of these packages. The capabilities of the dependencies are
+// analyzed on the way, like it is done by the analysis drivers. The module in
+// dir is the main module of the analysis, regardless of WithDir.
+//
+// Analyze runs the analyzer the same way as the analysis drivers do, so both
+// report the same findings. Like the analysis drivers, it loads the syntax and
+// the types of all the packages, including the dependencies, since the
+// capabilities of a dependency are computed from its syntax and passed on as
+// fact. For large dependency graphs, this load dominates the time and memory
+// of the analysis. Only the findings are returned, the diagnostics reported
+// by the analyzer are discarded, since they are built from the same
+// findings.
+//
+// The findings are sorted by package, position and capability. With
+// WithPerModule, the violations are merged per module and capability. If ctx
+// is done or the timeout of the Linter is reached, the findings of the
+// packages analyzed so far are returned together with an error wrapping the
+// error of ctx.
+func (d *Linter) Analyze(ctx context.Context, dir string, patterns ...string) ([]Finding, error) {
+	d = d.forDir(dir)
+
+	if d.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, d.timeout)
+		defer cancel()
+	}
+
+	err := d.Init(ctx)
+	if err != nil {
+		// The errors of the go commands do not wrap the error of ctx.
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		return nil, err
+	}
+
+	// The mode matches the one of the analysis drivers for analyzers with
+	// facts.
+	cfg := &packages.Config{
+		Context: ctx,
+		Mode:    packages.LoadAllSyntax | packages.NeedModule,
+		Dir:     dir,
+		Env:     d.environ(),
+	}
+	roots, err := packages.Load(cfg, patterns...)
+	if err != nil {
+		// The error of the go command does not wrap the error of ctx.
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		return nil, err
+	}
+
+	var errs []error
+	modules := make(map[string]*packages.Module)
+	packages.Visit(roots, nil, func(pkg *packages.Package) {
+		for _, err := range pkg.Errors {
+			errs = append(errs, err)
+		}
+		modules[pkg.PkgPath] = pkg.Module
+	})
+	if len(errs) > 0 {
+		return nil, errors.Join(errs...)
+	}
+
+	// The packages are analyzed with ctx instead of the context of the
+	// analyzer.
+	analyzer := d.AsAnalyzer(false)
+	analyzer.Run = func(pass *analysis.Pass) (interface{}, error) {
+		return d.runContext(ctx, pass)
+	}
+
+	drv := &driver{
+		ctx:      ctx,
+		analyzer: analyzer,
+		actions:  make(map[*packages.Package]*action),
+		facts:    make(map[*types.Package]analysis.Fact),
+		sem:      make(chan struct{}, runtime.GOMAXPROCS(0)),
+	}
+
+	for _, root := range roots {
+		drv.schedule(root)
+	}
+
+	var findings []Finding
+	var incomplete []string
+	for _, root := range roots {
+		act := drv.actions[root]
+		select {
+		case <-act.done:
+		case <-ctx.Done():
+			// A package, which is still analyzed, is not waited for.
+			select {
+			case <-act.done:
+			default:
+				incomplete = append(incomplete, root.PkgPath)
+				continue
+			}
+		}
+		if act.err != nil {
+			if ctx.Err() != nil && isContextError(act.err) {
+				incomplete = append(incomplete, root.PkgPath)
+				continue
+			}
+			return nil, fmt.Errorf("%s: %w", root.PkgPath, act.err)
+		}
+		findings = append(findings, act.findings...)
+	}
+
+	modulePath := func(pkg string) string {
+		if mod := modules[pkg]; mod != nil {
+			return mod.Path
+		}
+		return ""
+	}
+	for i := range findings {
+		f := &findings[i]
+		if f.Unapproved {
+			continue
+		}
+		if mod := modules[f.Dependency]; mod != nil {
+			f.Module = mod.Path
+			f.Version = mod.Version
+		}
+		for j := range f.Path {
+			f.Path[j].Module = modulePath(f.Path[j].Package)
+		}
+		for _, path := range f.Paths {
+			for j := range path {
+				path[j].Module = modulePath(path[j].Package)
+			}
+		}
+	}
+
+	if d.perModule {
+		findings = d.moduleFindings(findings)
+	}
+
+	sortFindings(findings)
+
+	if len(incomplete) > 0 {
+		return findings, fmt.Errorf("analysis of %d of %d packages incomplete: %w", len(incomplete), len(roots), ctx.Err())
+	}
+
+	return findings, nil
+}
+
+// forDir returns the Linter for the analysis of the main module in dir. The
+// Linter is prepared by Init for a single main module, so for any other
+// directory than the one of WithDir, a copy of the Linter is returned.
+func (d *Linter) forDir(dir string) *Linter {
+	if sameDir(d.dir, dir) {
+		return d
+	}
+
+	l := *d
+	l.dir = dir
+	l.once = &sync.Once{}
+	l.filesMu = &sync.Mutex{}
+	l.files = nil
+	return &l
+}
+
+// sameDir reports, if a and b denote the same directory. An empty directory
+// denotes the working directory.
+func sameDir(a, b string) bool {
+	absA, errA := filepath.Abs(a)
+	absB, errB := filepath.Abs(b)
+	return errA == nil && errB == nil && absA == absB
+}
+
+func isContextError(err error) bool {
+	return errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded)
+}
+
+// driver runs the analyzer on packages and their dependencies. Every package
+// is analyzed as soon as all its dependencies are analyzed.
+type driver struct {
+	ctx      context.Context
+	analyzer *analysis.Analyzer
+
+	mu      sync.Mutex
+	actions map[*packages.Package]*action
+	facts   map[*types.Package]analysis.Fact
+
+	sem chan struct{}
+}
+
+// action is the analysis of a single package.
+type action struct {
+	done     chan struct{}
+	findings []Finding
+	err      error
+}
+
+// schedule starts the analysis of pkg and all its dependencies, if not
+// already started, and returns the action of pkg.
+func (drv *driver) schedule(pkg *packages.Package) *action {
+	drv.mu.Lock()
+	act, ok := drv.actions[pkg]
+	if !ok {
+		act = &action{done: make(chan struct{})}
+		drv.actions[pkg] = act
+	}
+	drv.mu.Unlock()
+	if ok {
+		return act
+	}
+
+	deps := make([]*action, 0, len(pkg.Imports))
+	for _, imp := range pkg.Imports {
+		deps = append(deps, drv.schedule(imp))
+	}
+
+	go func() {
+		defer close(act.done)
+
+		for _, dep := range deps {
+			<-dep.done
+			if dep.err != nil {
+				act.err = dep.err
+				return
+			}
+		}
+
+		select {
+		case drv.sem <- struct{}{}:
+		case <-drv.ctx.Done():
+			act.err = drv.ctx.Err()
+			return
+		}
+		defer func() { <-drv.sem }()
+
+		act.findings, act.err = drv.run(pkg)
+	}()
+
+	return act
+}
+
+func (drv *driver) run(pkg *packages.Package) ([]Finding, error) {
+	module := &analysis.Module{}
+	if pkg.Module != nil {
+		module.Path = pkg.Module.Path
+		module.Version = pkg.Module.Version
+		module.GoVersion = pkg.Module.GoVersion
+	}
+
+	pass := &analysis.Pass{
+		Analyzer:     drv.analyzer,
+		Fset:         pkg.Fset,
+		Files:        pkg.Syntax,
+		OtherFiles:   pkg.OtherFiles,
+		IgnoredFiles: pkg.IgnoredFiles,
+		Pkg:          pkg.Types,
+		TypesInfo:    pkg.TypesInfo,
+		TypesSizes:   pkg.TypesSizes,
+		TypeErrors:   pkg.TypeErrors,
+		Module:       module,
+		ResultOf:     map[*analysis.Analyzer]interface{}{},
+		ReadFile:     readFile(pkg),
+
+		// The findings are returned as result of the pass, so the
+		// diagnostics are not needed.
+		Report: func(analysis.Diagnostic) {},
+
+		ImportObjectFact: func(types.Object, analysis.Fact) bool { return false },
+		ExportObjectFact: func(types.Object, analysis.Fact) {},
+		AllObjectFacts:   func() []analysis.ObjectFact { return nil },
+
+		ImportPackageFact: func(p *types.Package, fact analysis.Fact) bool {
+			drv.mu.Lock()
+			defer drv.mu.Unlock()
+
+			f, ok := drv.facts[p]
+			if !ok {
+				return false
+			}
+			reflect.ValueOf(fact).Elem().Set(reflect.ValueOf(f).Elem())
+			return true
+		},
+		ExportPackageFact: func(fact analysis.Fact) {
+			drv.mu.Lock()
+			defer drv.mu.Unlock()
+
+			drv.facts[pkg.Types] = fact
+		},
+		AllPackageFacts: func() []analysis.PackageFact {
+			return drv.allPackageFacts(pkg.Types)
+		},
+	}
+
+	result, err := drv.analyzer.Run(pass)
+	if err != nil {
+		return nil, err
+	}
+
+	findings, _ := result.([]Finding)
+	return findings, nil
+}
+
+// readFile returns the ReadFile function of the pass of pkg, which is
+// restricted to the files of pkg like in the analysis drivers.
+func readFile(pkg *packages.Package) func(string) ([]byte, error) {
+	files := make(map[string]bool)
+	for _, list := range [][]string{pkg.GoFiles, pkg.CompiledGoFiles, pkg.OtherFiles, pkg.IgnoredFiles, pkg.EmbedFiles} {
+		for _, file := range list {
+			files[file] = true
+		}
+	}
+
+	return func(filename string) ([]byte, error) {
+		if !files[filename] {
+			return nil, fmt.Errorf("Pass.ReadFile: %s is not among the files of package %s", filename, pkg.PkgPath)
+		}
+		return os.ReadFile(filename)
+	}
+}
+
+// allPackageFacts returns the facts of pkg and all the packages imported by
+// pkg, directly or indirectly.
+func (drv *driver) allPackageFacts(pkg *types.Package) []analysis.PackageFact {
+	drv.mu.Lock()
+	defer drv.mu.Unlock()
+
+	var facts []analysis.PackageFact
+	seen := make(map[*types.Package]bool)
+	var visit func(p *types.Package)
+	visit = func(p *types.Package) {
+		if seen[p] {
+			return
+		}
+		seen[p] = true
+
+		if f, ok := drv.facts[p]; ok {
+			facts = append(facts, analysis.PackageFact{Package: p, Fact: f})
+		}
+		for _, imp := range p.Imports() {
+			visit(imp)
+		}
+	}
+	visit(pkg)
+
+	return facts
+}
+
+func sortFindings(findings []Finding) {
+	sort.SliceStable(findings, func(i, j int) bool {
+		a, b := findings[i], findings[j]
+		if a.Package != b.Package {
+			return a.Package < b.Package
+		}
+		if a.Position.Filename != b.Position.Filename {
+			return a.Position.Filename < b.Position.Filename
+		}
+		if a.Position.Line != b.Position.Line {
+			return a.Position.Line < b.Position.Line
+		}
+		if a.Position.Column != b.Position.Column {
+			return a.Position.Column < b.Position.Column
+		}
+		return a.Capability.String() < b.Capability.String()
+	})
+}
@@ -0,0 +1,266 @@
+package depcaps
+
+import (
+	"bytes"
+	"encoding/gob"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/google/capslock/proto"
+	pb "google.golang.org/protobuf/proto"
+)
+
+// packageCapabilities is the fact exported for every analyzed package. It
+// holds the capabilities of the functions of the package.
+//
+// Every capability of a function is represented by a capability info in the
+// same form as produced by capslock, but the path is limited to the function
+// itself and, if the capability is not a direct one, the callee of the
+// function, through which the capability is reached. The full path is
+// reconstructed by following the callees through the facts of their
+// packages.
+//
+// Additionally the fact holds the program summary of the package, such that
+// the dynamic calls can be resolved against the types of the whole program,
+// and the ID of the cache key of the package, which is part of the cache keys
+// of the importing packages. Without cache key, the ID is empty.
+type packageCapabilities struct {
+	cil     *proto.CapabilityInfoList
+	program *programSummary
+	keyID   string
+
+	once      *sync.Once
+	functions map[string]map[proto.Capability]*proto.CapabilityInfo
+}
+
+func newPackageCapabilities(cil *proto.CapabilityInfoList) *packageCapabilities {
+	return &packageCapabilities{
+		cil:  cil,
+		once: &sync.Once{},
+	}
+}
+
+func (*packageCapabilities) AFact() {}
+
+func (p *packageCapabilities) String() string {
+	var names []string
+	for name := range p.index() {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	return fmt.Sprintf("capabilities(%s)", strings.Join(names, ", "))
+}
+
+// encodedCapabilities is the encoded form of packageCapabilities.
+type encodedCapabilities struct {
+	CapabilityInfoList []byte
+	Program            *encodedProgram
+	KeyID              string
+}
+
+// encodedProgram is the encoded form of programSummary. The strings are
+// stored once, as the same names, method keys and nodes are repeated many
+// times.
+type encodedProgram struct {
+	Strings  []string
+	Packages map[int]encodedPackage
+}
+
+type encodedPackage struct {
+	Reaches map[int]encodedReach
+	Sites   map[int]encodedCall
+	Types   map[int]encodedType
+	Flows   map[int]encodedFlow
+}
+
+type encodedReach struct {
+	Sites, Vias []int
+}
+
+type encodedCall struct {
+	Interface, Method int
+}
+
+type encodedType struct {
+	Keys    []int
+	Methods map[int]encodedMethod
+}
+
+type encodedMethod struct {
+	Name, Package int
+	Capabilities  []proto.Capability
+}
+
+type encodedFlow struct {
+	From, Types []int
+}
+
+func encodeProgram(ps *programSummary) *encodedProgram {
+	if ps == nil {
+		return nil
+	}
+
+	ep := &encodedProgram{Packages: make(map[int]encodedPackage, len(ps.Packages))}
+	index := make(map[string]int)
+	intern := func(str string) int {
+		i, ok := index[str]
+		if !ok {
+			i = len(ep.Strings)
+			index[str] = i
+			ep.Strings = append(ep.Strings, str)
+		}
+		return i
+	}
+	internAll := func(strs []string) []int {
+		is := make([]int, 0, len(strs))
+		for _, str := range strs {
+			is = append(is, intern(str))
+		}
+		return is
+	}
+
+	for pkgPath, part := range ps.Packages {
+		epp := encodedPackage{
+			Reaches: make(map[int]encodedReach, len(part.Reaches)),
+			Sites:   make(map[int]encodedCall, len(part.Sites)),
+			Types:   make(map[int]encodedType, len(part.Types)),
+			Flows:   make(map[int]encodedFlow, len(part.Flows)),
+		}
+		for name, r := range part.Reaches {
+			epp.Reaches[intern(name)] = encodedReach{Sites: internAll(r.Sites), Vias: internAll(r.Vias)}
+		}
+		for node, call := range part.Sites {
+			epp.Sites[intern(node)] = encodedCall{Interface: intern(call.Interface), Method: intern(call.Method)}
+		}
+		for name, t := range part.Types {
+			et := encodedType{
+				Keys:    internAll(t.Keys),
+				Methods: make(map[int]encodedMethod, len(t.Methods)),
+			}
+			for key, m := range t.Methods {
+				et.Methods[intern(key)] = encodedMethod{Name: intern(m.Name), Package: intern(m.Package), Capabilities: m.Capabilities}
+			}
+			epp.Types[intern(name)] = et
+		}
+		for node, tf := range part.Flows {
+			epp.Flows[intern(node)] = encodedFlow{From: internAll(tf.From), Types: internAll(tf.Types)}
+		}
+		ep.Packages[intern(pkgPath)] = epp
+	}
+
+	return ep
+}
+
+func decodeProgram(ep *encodedProgram) (*programSummary, error) {
+	if ep == nil {
+		return nil, nil
+	}
+
+	var err error
+	str := func(i int) string {
+		if i < 0 || i >= len(ep.Strings) {
+			err = fmt.Errorf("invalid string index %d", i)
+			return ""
+		}
+		return ep.Strings[i]
+	}
+	strs := func(is []int) []string {
+		if len(is) == 0 {
+			return nil
+		}
+		ss := make([]string, 0, len(is))
+		for _, i := range is {
+			ss = append(ss, str(i))
+		}
+		return ss
+	}
+
+	ps := &programSummary{Packages: make(map[string]*programPackage, len(ep.Packages))}
+	for pkgPath, epp := range ep.Packages {
+		part := &programPackage{
+			Reaches: make(map[string]reach, len(epp.Reaches)),
+			Sites:   make(map[string]dynamicCall, len(epp.Sites)),
+			Types:   make(map[string]*programType, len(epp.Types)),
+			Flows:   make(map[string]*typeFlow, len(epp.Flows)),
+		}
+		for name, er := range epp.Reaches {
+			part.Reaches[str(name)] = reach{Sites: strs(er.Sites), Vias: strs(er.Vias)}
+		}
+		for node, ec := range epp.Sites {
+			part.Sites[str(node)] = dynamicCall{Interface: str(ec.Interface), Method: str(ec.Method)}
+		}
+		for name, et := range epp.Types {
+			t := &programType{
+				Keys:    make([]string, 0, len(et.Keys)),
+				Methods: make(map[string]programMethod, len(et.Methods)),
+			}
+			t.Keys = append(t.Keys, strs(et.Keys)...)
+			for key, em := range et.Methods {
+				t.Methods[str(key)] = programMethod{Name: str(em.Name), Package: str(em.Package), Capabilities: em.Capabilities}
+			}
+			part.Types[str(name)] = t
+		}
+		for node, ef := range epp.Flows {
+			part.Flows[str(node)] = &typeFlow{From: strs(ef.From), Types: strs(ef.Types)}
+		}
+		ps.Packages[str(pkgPath)] = part
+	}
+
+	return ps, err
+}
+
+func (p *packageCapabilities) GobEncode() ([]byte, error) {
+	cil, err := pb.Marshal(p.cil)
+	if err != nil {
+		return nil, err
+	}
+
+	var buf bytes.Buffer
+	err = gob.NewEncoder(&buf).Encode(encodedCapabilities{CapabilityInfoList: cil, Program: encodeProgram(p.program), KeyID: p.keyID})
+	if err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func (p *packageCapabilities) GobDecode(data []byte) error {
+	var encoded encodedCapabilities
+	if err := gob.NewDecoder(bytes.NewReader(data)).Decode(&encoded); err != nil {
+		return err
+	}
+
+	p.cil = &proto.CapabilityInfoList{}
+	p.once = &sync.Once{}
+	program, err := decodeProgram(encoded.Program)
+	if err != nil {
+		return err
+	}
+	p.program = program
+	p.keyID = encoded.KeyID
+	return pb.Unmarshal(encoded.CapabilityInfoList, p.cil)
+}
+
+// function returns the capabilities of the function with the given name.
+func (p *packageCapabilities) function(name string) map[proto.Capability]*proto.CapabilityInfo {
+	return p.index()[name]
+}
+
+func (p *packageCapabilities) index() map[string]map[proto.Capability]*proto.CapabilityInfo {
+	p.once.Do(func() {
+		p.functions = make(map[string]map[proto.Capability]*proto.CapabilityInfo)
+		for _, ci := range p.cil.GetCapabilityInfo() {
+			if len(ci.GetPath()) == 0 {
+				continue
+			}
+			name := ci.GetPath()[0].GetName()
+			if p.functions[name] == nil {
+				p.functions[name] = make(map[proto.Capability]*proto.CapabilityInfo)
+			}
+			p.functions[name][ci.GetCapability()] = ci
+		}
+	})
+	return p.functions
+}
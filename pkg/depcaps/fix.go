@@ -0,0 +1,467 @@
+package depcaps
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/google/capslock/proto"
+	"golang.org/x/tools/go/analysis"
+)
+
+const packageAllowedCapabilitiesKey = "PackageAllowedCapabilities"
+
+// jsonObject is a JSON object of the config file together with the offsets of
+// its braces and its members.
+type jsonObject struct {
+	start   int // offset of '{'
+	end     int // offset of '}'
+	members []jsonMember
+}
+
+// jsonMember is a member of a JSON object.
+type jsonMember struct {
+	key        string
+	keyStart   int // offset of the opening quote of the key
+	valueStart int
+	valueEnd   int         // offset after the value
+	object     *jsonObject // nil, if the value is not an object
+}
+
+// member returns the first member of o with the given key.
+func (o *jsonObject) member(key string) *jsonMember {
+	for i := range o.members {
+		if o.members[i].key == key {
+			return &o.members[i]
+		}
+	}
+	return nil
+}
+
+// parseJSONObject parses content, which is expected to hold a single JSON
+// object, and returns the object including the offsets of all the members.
+func parseJSONObject(content []byte) (*jsonObject, error) {
+	dec := json.NewDecoder(bytes.NewReader(content))
+	tok, err := dec.Token()
+	if err != nil {
+		return nil, err
+	}
+	if tok != json.Delim('{') {
+		return nil, errors.New("expected JSON object")
+	}
+
+	return parseObjectMembers(dec, content)
+}
+
+// parseObjectMembers parses the members of the object, whose opening brace
+// has just been read from dec.
+func parseObjectMembers(dec *json.Decoder, content []byte) (*jsonObject, error) {
+	obj := &jsonObject{start: int(dec.InputOffset()) - 1}
+	for dec.More() {
+		keyStart := skip(content, int(dec.InputOffset()), " \t\r\n,")
+		tok, err := dec.Token()
+		if err != nil {
+			return nil, err
+		}
+		key, ok := tok.(string)
+		if !ok {
+			return nil, fmt.Errorf("expected object key at offset %d", keyStart)
+		}
+
+		m := jsonMember{
+			key:        key,
+			keyStart:   keyStart,
+			valueStart: skip(content, int(dec.InputOffset()), " \t\r\n:"),
+		}
+		if m.valueStart < len(content) && content[m.valueStart] == '{' {
+			_, err = dec.Token()
+			if err != nil {
+				return nil, err
+			}
+			m.object, err = parseObjectMembers(dec, content)
+			if err != nil {
+				return nil, err
+			}
+		} else {
+			var raw json.RawMessage
+			err = dec.Decode(&raw)
+			if err != nil {
+				return nil, err
+			}
+		}
+		m.valueEnd = int(dec.InputOffset())
+		obj.members = append(obj.members, m)
+	}
+
+	_, err := dec.Token()
+	if err != nil {
+		return nil, err
+	}
+	obj.end = int(dec.InputOffset()) - 1
+
+	return obj, nil
+}
+
+func skip(content []byte, offset int, chars string) int {
+	for offset < len(content) && strings.IndexByte(chars, content[offset]) >= 0 {
+		offset++
+	}
+	return offset
+}
+
+// insertion is a text to be inserted at offset. If end is greater than
+// offset, the text between offset and end is replaced.
+type insertion struct {
+	offset int
+	end    int
+	text   string
+}
+
+// member is a member of a JSON object to be inserted, value is the JSON text
+// of its value.
+type member struct {
+	key   string
+	value string
+}
+
+// configInsertions returns the insertions, which add caps to the
+// PackageAllowedCapabilities of the config file with content and the parsed
+// object root. caps holds the capabilities per package, sorted by name.
+//
+// The packages and capabilities are inserted at their sorted position, a
+// missing or empty object is created with all its members. The members
+// inserted at the same position are combined into a single insertion, which
+// replaces the white space to the neighbouring members as well. Inserting
+// different members at the same position is a conflict for the analysis
+// driver then, instead of a repeated key.
+func configInsertions(content []byte, root *jsonObject, caps map[string][]proto.Capability) ([]insertion, error) {
+	unit := indentUnit(content, root)
+
+	pkgs := make([]string, 0, len(caps))
+	for pkg := range caps {
+		pkgs = append(pkgs, pkg)
+	}
+	sort.Strings(pkgs)
+
+	pac := root.member(packageAllowedCapabilitiesKey)
+	if pac == nil {
+		return memberInsertions(content, root, "", unit, []member{{key: packageAllowedCapabilitiesKey, value: packagesText(unit, 1, pkgs, caps)}}), nil
+	}
+	if pac.object == nil {
+		return nil, fmt.Errorf("%s is not an object", packageAllowedCapabilitiesKey)
+	}
+
+	var insertions []insertion
+	var created []member
+	for _, pkg := range pkgs {
+		p := pac.object.member(pkg)
+		if p == nil {
+			created = append(created, member{key: pkg, value: capabilitiesText(unit, 2, caps[pkg])})
+			continue
+		}
+		if p.object == nil {
+			return nil, fmt.Errorf("%s of package %s is not an object", packageAllowedCapabilitiesKey, pkg)
+		}
+
+		members := make([]member, 0, len(caps[pkg]))
+		for _, c := range caps[pkg] {
+			members = append(members, member{key: c.String(), value: "true"})
+		}
+		insertions = append(insertions, memberInsertions(content, p.object, strings.Repeat(unit, 2), unit, members)...)
+	}
+	insertions = append(insertions, memberInsertions(content, pac.object, unit, unit, created)...)
+
+	sort.Slice(insertions, func(i, j int) bool {
+		return insertions[i].offset < insertions[j].offset
+	})
+
+	return insertions, nil
+}
+
+// memberInsertions returns the insertions of members, which are sorted by
+// key, into obj, which is indented by indent. If obj has no members, it is
+// replaced by an object with the members indented by one more unit.
+// Otherwise, every member is inserted before the first member of obj with a
+// greater key, or after the last member, and indented like the existing
+// members. Members inserted at the same position are combined into a single
+// insertion, which replaces the white space to the neighbouring members as
+// well.
+func memberInsertions(content []byte, obj *jsonObject, indent, unit string, members []member) []insertion {
+	if len(members) == 0 {
+		return nil
+	}
+
+	if len(obj.members) == 0 {
+		var b strings.Builder
+		b.WriteString("{")
+		for i, m := range members {
+			if i > 0 {
+				b.WriteString(",")
+			}
+			fmt.Fprintf(&b, "\n%s%s%q: %s", indent, unit, m.key, m.value)
+		}
+		fmt.Fprintf(&b, "\n%s}", indent)
+		return []insertion{{offset: obj.start, end: obj.end + 1, text: b.String()}}
+	}
+
+	memberIndent := lineIndent(content, obj.members[0].keyStart)
+	last := obj.members[len(obj.members)-1]
+
+	var insertions []insertion
+	var next []int // index of the member of obj, in front of which an insertion is made
+	for _, m := range members {
+		i := len(obj.members)
+		for j := range obj.members {
+			if m.key < obj.members[j].key {
+				i = j
+				break
+			}
+		}
+
+		ins := insertion{offset: last.valueEnd, end: last.valueEnd, text: fmt.Sprintf(",\n%s%q: %s", memberIndent, m.key, m.value)}
+		if i < len(obj.members) {
+			keyStart := obj.members[i].keyStart
+			ins = insertion{offset: keyStart, end: keyStart, text: fmt.Sprintf("%q: %s,\n%s", m.key, m.value, memberIndent)}
+		}
+
+		if n := len(insertions); n > 0 && next[n-1] == i {
+			insertions[n-1].text += ins.text
+			continue
+		}
+		insertions = append(insertions, ins)
+		next = append(next, i)
+	}
+
+	for k := range insertions {
+		ins := &insertions[k]
+		switch i := next[k]; {
+		case i == len(obj.members):
+			ins.text += string(content[ins.end:obj.end])
+			ins.end = obj.end
+		case i == 0:
+			ins.text = string(content[obj.start+1:ins.offset]) + ins.text
+			ins.offset = obj.start + 1
+		default:
+			ins.text = string(content[obj.members[i-1].valueEnd:ins.offset]) + ins.text
+			ins.offset = obj.members[i-1].valueEnd
+		}
+	}
+
+	return insertions
+}
+
+// packagesText returns an object holding the capabilities of pkgs, as the
+// value of a member with the given indentation level.
+func packagesText(unit string, level int, pkgs []string, caps map[string][]proto.Capability) string {
+	var b strings.Builder
+	b.WriteString("{\n")
+	for i, pkg := range pkgs {
+		if i > 0 {
+			b.WriteString(",\n")
+		}
+		fmt.Fprintf(&b, "%s%q: %s", strings.Repeat(unit, level+1), pkg, capabilitiesText(unit, level+1, caps[pkg]))
+	}
+	fmt.Fprintf(&b, "\n%s}", strings.Repeat(unit, level))
+	return b.String()
+}
+
+// capabilitiesText returns an object holding caps, as the value of a member
+// with the given indentation level.
+func capabilitiesText(unit string, level int, caps []proto.Capability) string {
+	var b strings.Builder
+	b.WriteString("{\n")
+	for i, c := range caps {
+		if i > 0 {
+			b.WriteString(",\n")
+		}
+		fmt.Fprintf(&b, "%s%q: true", strings.Repeat(unit, level+1), c.String())
+	}
+	fmt.Fprintf(&b, "\n%s}", strings.Repeat(unit, level))
+	return b.String()
+}
+
+// indentUnit returns the indentation of the members of obj relative to obj.
+// It defaults to two spaces.
+func indentUnit(content []byte, obj *jsonObject) string {
+	if len(obj.members) == 0 {
+		return "  "
+	}
+	indent := strings.TrimPrefix(lineIndent(content, obj.members[0].keyStart), lineIndent(content, obj.start))
+	if indent == "" {
+		return "  "
+	}
+	return indent
+}
+
+// lineIndent returns the white space in front of offset, if offset is the
+// first non white space character on its line.
+func lineIndent(content []byte, offset int) string {
+	start := bytes.LastIndexByte(content[:offset], '\n') + 1
+	indent := string(content[start:offset])
+	if strings.TrimLeft(indent, " \t") != "" {
+		return ""
+	}
+	return indent
+}
+
+// configFile is the config file, which is edited by the fixes.
+type configFile struct {
+	name    string
+	content []byte
+	root    *jsonObject
+}
+
+// readConfigFile reads and parses the config file with the given name.
+func readConfigFile(name string) (*configFile, error) {
+	content, err := os.ReadFile(name)
+	if err != nil {
+		return nil, err
+	}
+
+	root, err := parseJSONObject(content)
+	if err != nil {
+		return nil, fmt.Errorf("config file %s: %w", name, err)
+	}
+
+	return &configFile{name: name, content: content, root: root}, nil
+}
+
+// fixCapabilities returns the capabilities, which the fix of the findings
+// of pkg allows: all the capabilities of pkg in its fact, which are neither
+// allowed nor denied. The capabilities only depend on the config file and the
+// fact of pkg, so the fixes of pkg reported by the packages of the own
+// module, including their test variants, are identical and the analysis
+// driver is able to apply them together. Allowing a denied capability has no
+// effect, so denied capabilities are not fixed. Capabilities, which pkg only
+// gains through the types of the own module, are not part of its fact and
+// have no suggested fix, see Fix.
+func (d *Linter) fixCapabilities(fact *packageCapabilities, pkg string) []proto.Capability {
+	if fact == nil {
+		return nil
+	}
+
+	caps := make(map[proto.Capability]struct{})
+	for _, functionCaps := range fact.index() {
+		for c := range functionCaps {
+			if d.allowedBy(pkg, c) != AllowedBaseline {
+				continue
+			}
+			if _, denied := d.denied(pkg, c); denied {
+				continue
+			}
+			caps[c] = struct{}{}
+		}
+	}
+	return sortedCapabilityNames(caps)
+}
+
+// suggestedFixes returns the fix, which allows caps for pkg in the config
+// file. The edits only depend on the config file, pkg and caps, so the fixes
+// of pkg reported by several packages of the own module are identical, see
+// fixCapabilities. The fixes of different dependency packages do not
+// overlap, unless both packages are created at the same position in the
+// config file, see configInsertions.
+func (d *Linter) suggestedFixes(pass *analysis.Pass, pkg string, caps []proto.Capability) ([]analysis.SuggestedFix, error) {
+	if d.config == nil || len(caps) == 0 {
+		return nil, nil
+	}
+
+	insertions, err := configInsertions(d.config.content, d.config.root, map[string][]proto.Capability{pkg: caps})
+	if err != nil {
+		return nil, fmt.Errorf("config file %s: %w", d.config.name, err)
+	}
+
+	file := d.tokenFile(pass.Fset, d.config.name, func() ([]byte, error) {
+		return d.config.content, nil
+	})
+
+	edits := make([]analysis.TextEdit, 0, len(insertions))
+	for _, ins := range insertions {
+		edits = append(edits, analysis.TextEdit{
+			Pos:     file.Pos(ins.offset),
+			End:     file.Pos(ins.end),
+			NewText: []byte(ins.text),
+		})
+	}
+
+	names := make([]string, 0, len(caps))
+	for _, c := range caps {
+		names = append(names, c.String())
+	}
+
+	return []analysis.SuggestedFix{
+		{
+			Message:   fmt.Sprintf("Allow %s for package %s in %s", strings.Join(names, ", "), pkg, filepath.Base(d.config.name)),
+			TextEdits: edits,
+		},
+	}, nil
+}
+
+// Fix adds the not allowed capabilities of the findings to
+// PackageAllowedCapabilities in the config file. Unlike the suggested fixes,
+// which allow all the capabilities of a dependency package, see
+// fixCapabilities, Fix knows the findings of all the packages of the own
+// module and only allows the reported capabilities.
+func (d *Linter) Fix(findings []Finding) error {
+	if d.configFile == "" {
+		return nil
+	}
+
+	allowed := make(map[string]map[proto.Capability]struct{})
+	var collect func(findings []Finding)
+	collect = func(findings []Finding) {
+		for _, f := range findings {
+			collect(f.Affected)
+			if f.Decision != Violation || f.Dependency == "" {
+				continue
+			}
+			// Allowing a denied capability has no effect, so there is no fix.
+			if _, denied := d.denied(f.Dependency, f.Capability); denied {
+				continue
+			}
+			if allowed[f.Dependency] == nil {
+				allowed[f.Dependency] = make(map[proto.Capability]struct{})
+			}
+			allowed[f.Dependency][f.Capability] = struct{}{}
+		}
+	}
+	collect(findings)
+	if len(allowed) == 0 {
+		return nil
+	}
+
+	caps := make(map[string][]proto.Capability, len(allowed))
+	for pkg, c := range allowed {
+		caps[pkg] = sortedCapabilityNames(c)
+	}
+
+	config, err := readConfigFile(d.configFile)
+	if err != nil {
+		return err
+	}
+
+	insertions, err := configInsertions(config.content, config.root, caps)
+	if err != nil {
+		return fmt.Errorf("config file %s: %w", d.configFile, err)
+	}
+
+	var b bytes.Buffer
+	offset := 0
+	for _, ins := range insertions {
+		b.Write(config.content[offset:ins.offset])
+		b.WriteString(ins.text)
+		offset = ins.end
+	}
+	b.Write(config.content[offset:])
+
+	info, err := os.Stat(d.configFile)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(d.configFile, b.Bytes(), info.Mode().Perm())
+}
@@ -0,0 +1,693 @@
+package depcaps
+
+import (
+	"fmt"
+	"go/token"
+	"go/types"
+	"sort"
+	"strings"
+
+	"golang.org/x/tools/go/ssa"
+)
+
+// The dynamic calls of the dependencies are resolved against the types, which
+// flow to the receiver of the call, like capslock does with the variable type
+// analysis (VTA) of golang.org/x/tools/go/callgraph/vta. The type flow graph
+// of a package has the nodes and edges of the graph of VTA, but the nodes,
+// which other packages might share, are identified by strings, such that the
+// graph can be recorded in the fact:
+//
+//   - "global NAME" for package level variables,
+//   - "param FUNC I" and "result FUNC I" for the parameters and results of
+//     functions,
+//   - "field STRUCT I" for the fields of structs,
+//   - "elem T", "mapkey T", "mapvalue T" and "chan T" for the elements of
+//     slices, arrays, maps and channels of element type T,
+//   - "pointer T" for nested pointers to the interface T,
+//   - "panic" and "recover" for the values passed to panic and returned by
+//     recover,
+//   - "method KEY param I" and "method KEY result I" for the parameters and
+//     results of the methods with the method key KEY and "func SIG param I"
+//     and "func SIG result I" for the ones of the functions with signature
+//     SIG, which are used as values. They stand for the callees of dynamic
+//     calls, like the initial call graph of VTA does,
+//   - "site FUNC I" for the receiver of the Ith dynamic call of a function.
+//
+// Only the nodes holding interfaces are part of the graph. The concrete types
+// enter the graph, where they are converted to an interface.
+
+// typeFlow holds the flows into a node of the type flow graph, the nodes From
+// flowing into the node and the concrete Types converted to an interface.
+type typeFlow struct {
+	From  []string
+	Types []string
+}
+
+// flowNode is a node of the type flow graph. Local values of functions are
+// identified by the value and, for the elements of tuples, their index, all
+// the other nodes by their key.
+type flowNode struct {
+	key   string
+	val   ssa.Value
+	index int
+}
+
+// flowBuilder builds the type flow graph of the functions of a package.
+type flowBuilder struct {
+	pkg *ssa.Package
+	// bodies holds the functions, whose body is part of the graph, which are
+	// the functions of the package and the synthetic functions, e.g.
+	// instances of generic functions, used by them.
+	bodies map[*ssa.Function]bool
+	linked map[*ssa.Function]bool
+
+	ids   map[flowNode]int
+	nodes []flowNode
+	// ref reports, if the node is a pointer, which might alias other
+	// pointers, and hidden, if the node is only visible to the package.
+	ref    []bool
+	hidden []bool
+	succs  []map[int]bool
+	types  []map[string]bool
+}
+
+// flowSummary returns the type flow graph of the functions of ssapkg by node.
+// The nodes only visible to the package are removed and their flows are
+// replaced by the flows between the remaining nodes.
+func flowSummary(ssapkg *ssa.Package) map[string]*typeFlow {
+	b := &flowBuilder{
+		pkg:    ssapkg,
+		bodies: make(map[*ssa.Function]bool),
+		linked: make(map[*ssa.Function]bool),
+		ids:    make(map[flowNode]int),
+	}
+
+	var queue []*ssa.Function
+	enqueue := func(fn *ssa.Function) {
+		if fn != nil && len(fn.Blocks) > 0 && !b.bodies[fn] {
+			b.bodies[fn] = true
+			queue = append(queue, fn)
+		}
+	}
+	for _, fn := range packageFunctions(ssapkg) {
+		enqueue(fn)
+	}
+	var buf [10]*ssa.Value
+	for i := 0; i < len(queue); i++ {
+		for _, anon := range queue[i].AnonFuncs {
+			enqueue(anon)
+		}
+		for _, bl := range queue[i].Blocks {
+			for _, instr := range bl.Instrs {
+				for _, op := range instr.Operands(buf[:0]) {
+					if fn, ok := (*op).(*ssa.Function); ok {
+						enqueue(fn)
+					}
+				}
+			}
+		}
+	}
+
+	// The order of the functions does not matter, as the nodes of the graph
+	// are identified by their keys.
+	for _, fn := range queue {
+		b.function(fn)
+		if fn.Pkg == ssapkg && fn.Synthetic == "" && fn.Signature.Recv() != nil {
+			b.method(fn)
+		}
+	}
+
+	return b.summary()
+}
+
+// add returns the node n holding values of type t or -1, if the type is not
+// an interface. Nodes without type hold interfaces of any type.
+func (b *flowBuilder) add(n flowNode, t types.Type, hidden bool) int {
+	if i, ok := b.ids[n]; ok {
+		return i
+	}
+	if t != nil && !types.IsInterface(t) && interfaceUnderPtr(t) == nil {
+		return -1
+	}
+
+	i := len(b.nodes)
+	b.ids[n] = i
+	b.nodes = append(b.nodes, n)
+	isPointer := false
+	if t != nil {
+		_, isPointer = types.Unalias(t).(*types.Pointer)
+	}
+	b.ref = append(b.ref, isPointer)
+	b.hidden = append(b.hidden, hidden)
+	b.succs = append(b.succs, nil)
+	b.types = append(b.types, nil)
+	return i
+}
+
+// typed returns the node n for a value of type t. Like VTA, pointers to
+// concrete types are omitted and all the nested pointers to an interface share
+// a node.
+func (b *flowBuilder) typed(n flowNode, t types.Type, hidden bool) int {
+	if p, ok := types.Unalias(t).(*types.Pointer); ok && !types.IsInterface(p.Elem()) {
+		iface := interfaceUnderPtr(p.Elem())
+		if iface == nil {
+			return -1
+		}
+		return b.add(flowNode{key: "pointer " + types.TypeString(iface, nil)}, p, false)
+	}
+	return b.add(n, t, hidden)
+}
+
+// value returns the node of the value v.
+func (b *flowBuilder) value(v ssa.Value) int {
+	switch v := v.(type) {
+	case *ssa.Global:
+		obj := v.Object()
+		hidden := v.Pkg == b.pkg && (obj == nil || !obj.Exported())
+		return b.typed(flowNode{key: "global " + v.String()}, v.Type(), hidden)
+	case *ssa.Parameter:
+		fn := v.Parent()
+		for i, p := range fn.Params {
+			if p == v {
+				return b.param(fn, i, v.Type())
+			}
+		}
+		return -1
+	case *ssa.FreeVar, ssa.Instruction:
+		return b.typed(flowNode{val: v, index: -1}, v.Type(), true)
+	default:
+		// Constants and functions hold no interfaces.
+		return -1
+	}
+}
+
+// indexed returns the node of the element with index of the tuple v.
+func (b *flowBuilder) indexed(v ssa.Value, index int) int {
+	return b.add(flowNode{val: v, index: index}, v.Type().(*types.Tuple).At(index).Type(), true)
+}
+
+// param returns the node of the parameter i of fn, the receiver of methods
+// being the first parameter. The parameters of the functions, which other
+// packages can't call directly, are only visible to the package.
+func (b *flowBuilder) param(fn *ssa.Function, i int, t types.Type) int {
+	return b.typed(flowNode{key: fmt.Sprintf("param %s %d", fn, i)}, t, b.private(fn))
+}
+
+func (b *flowBuilder) result(fn *ssa.Function, i int, t types.Type) int {
+	return b.add(flowNode{key: fmt.Sprintf("result %s %d", fn, i)}, t, b.private(fn))
+}
+
+func (b *flowBuilder) private(fn *ssa.Function) bool {
+	if !b.bodies[fn] {
+		return false
+	}
+	obj := fn.Object()
+	return fn.Pkg != b.pkg || fn.Synthetic != "" || obj == nil || !obj.Exported()
+}
+
+// field returns the node of the field i of the struct type t.
+func (b *flowBuilder) field(t types.Type, i int) int {
+	st, ok := t.Underlying().(*types.Struct)
+	if !ok {
+		return -1
+	}
+	f := st.Field(i)
+	hidden := !f.Exported() && f.Pkg() == b.pkg.Pkg
+	return b.add(flowNode{key: fmt.Sprintf("field %s %d", types.TypeString(t, nil), i)}, f.Type(), hidden)
+}
+
+// shared returns the node with the given kind for the elements of type t.
+func (b *flowBuilder) shared(kind string, t types.Type) int {
+	return b.add(flowNode{key: kind + " " + types.TypeString(t, nil)}, t, false)
+}
+
+func (b *flowBuilder) special(key string) int {
+	return b.add(flowNode{key: key}, nil, false)
+}
+
+// flow adds the edge from -> to.
+func (b *flowBuilder) flow(from, to int) {
+	if from < 0 || to < 0 || from == to {
+		return
+	}
+	if b.succs[from] == nil {
+		b.succs[from] = make(map[int]bool)
+	}
+	b.succs[from][to] = true
+}
+
+// alias adds the edge r -> l and, if both are pointers, l -> r.
+func (b *flowBuilder) alias(l, r int) {
+	b.flow(r, l)
+	if l >= 0 && r >= 0 && b.ref[l] && b.ref[r] {
+		b.flow(l, r)
+	}
+}
+
+// source records the conversion of the concrete type t to an interface held
+// by node n. Only named types might have methods of interest.
+func (b *flowBuilder) source(n int, t types.Type) {
+	if named, ok := derefNamed(t); n < 0 || !ok || named.TypeArgs().Len() > 0 {
+		return
+	}
+	if b.types[n] == nil {
+		b.types[n] = make(map[string]bool)
+	}
+	b.types[n][types.TypeString(t, nil)] = true
+}
+
+func (b *flowBuilder) function(fn *ssa.Function) {
+	sites := 0
+	var buf [10]*ssa.Value
+	for _, bl := range fn.Blocks {
+		for _, instr := range bl.Instrs {
+			b.instr(instr)
+
+			if call, ok := instr.(ssa.CallInstruction); ok {
+				b.call(call, &sites)
+			}
+			for _, op := range instr.Operands(buf[:0]) {
+				if callee, ok := (*op).(*ssa.Function); ok {
+					if call, ok := instr.(ssa.CallInstruction); !ok || call.Common().Value != callee {
+						b.funcValue(callee)
+					}
+				}
+			}
+		}
+	}
+}
+
+func (b *flowBuilder) instr(instr ssa.Instruction) {
+	switch i := instr.(type) {
+	case *ssa.Store:
+		b.alias(b.value(i.Addr), b.value(i.Val))
+	case *ssa.MakeInterface:
+		b.source(b.value(i), i.X.Type())
+	case *ssa.MakeClosure:
+		fn := i.Fn.(*ssa.Function)
+		for j, fv := range fn.FreeVars {
+			b.alias(b.value(fv), b.value(i.Bindings[j]))
+		}
+	case *ssa.UnOp:
+		switch i.Op {
+		case token.MUL:
+			b.alias(b.value(i), b.value(i.X))
+		case token.ARROW:
+			if ch, ok := i.X.Type().Underlying().(*types.Chan); ok {
+				b.alias(b.value(i), b.shared("chan", ch.Elem()))
+			}
+		}
+	case *ssa.Phi:
+		for _, edge := range i.Edges {
+			b.alias(b.value(i), b.value(edge))
+		}
+	case *ssa.ChangeInterface:
+		b.flow(b.value(i.X), b.value(i))
+	case *ssa.ChangeType:
+		b.alias(b.value(i), b.value(i.X))
+	case *ssa.TypeAssert:
+		if i.CommaOk {
+			b.flow(b.value(i.X), b.indexed(i, 0))
+		} else {
+			b.flow(b.value(i.X), b.value(i))
+		}
+	case *ssa.Extract:
+		b.alias(b.value(i), b.indexed(i.Tuple, i.Index))
+	case *ssa.Field:
+		b.flow(b.field(i.X.Type(), i.Field), b.value(i))
+	case *ssa.FieldAddr:
+		if p, ok := i.X.Type().Underlying().(*types.Pointer); ok {
+			f := b.field(p.Elem(), i.Field)
+			b.flow(f, b.value(i))
+			b.flow(b.value(i), f)
+		}
+	case *ssa.Send:
+		if ch, ok := i.Chan.Type().Underlying().(*types.Chan); ok {
+			b.alias(b.shared("chan", ch.Elem()), b.value(i.X))
+		}
+	case *ssa.Select:
+		recv := 0
+		for _, state := range i.States {
+			ch, ok := state.Chan.Type().Underlying().(*types.Chan)
+			if !ok {
+				continue
+			}
+			if state.Dir == types.SendOnly {
+				b.alias(b.shared("chan", ch.Elem()), b.value(state.Send))
+			} else {
+				b.alias(b.indexed(i, 2+recv), b.shared("chan", ch.Elem()))
+				recv++
+			}
+		}
+	case *ssa.Index:
+		if elem := sliceArrayElem(i.X.Type()); elem != nil {
+			b.alias(b.value(i), b.shared("elem", elem))
+		}
+	case *ssa.IndexAddr:
+		if elem := sliceArrayElem(i.X.Type()); elem != nil {
+			b.flow(b.shared("elem", elem), b.value(i))
+			b.flow(b.value(i), b.shared("elem", elem))
+		}
+	case *ssa.Lookup:
+		if m, ok := i.X.Type().Underlying().(*types.Map); ok {
+			if i.CommaOk {
+				b.alias(b.indexed(i, 0), b.shared("mapvalue", m.Elem()))
+			} else {
+				b.alias(b.value(i), b.shared("mapvalue", m.Elem()))
+			}
+		}
+	case *ssa.MapUpdate:
+		if m, ok := i.Map.Type().Underlying().(*types.Map); ok {
+			b.alias(b.shared("mapkey", m.Key()), b.value(i.Key))
+			b.alias(b.shared("mapvalue", m.Elem()), b.value(i.Value))
+		}
+	case *ssa.Next:
+		if !i.IsString {
+			tuple := i.Type().(*types.Tuple)
+			b.alias(b.indexed(i, 1), b.shared("mapkey", tuple.At(1).Type()))
+			b.alias(b.indexed(i, 2), b.shared("mapvalue", tuple.At(2).Type()))
+		}
+	case *ssa.Panic:
+		b.flow(b.value(i.X), b.special("panic"))
+	case *ssa.Return:
+		for j, r := range i.Results {
+			b.flow(b.value(r), b.result(i.Parent(), j, r.Type()))
+		}
+	}
+}
+
+// call adds the flows between the arguments and the parameters and between
+// the results and the value of the call. The parameters and results of the
+// callees of dynamic calls are represented by the nodes of their method key
+// or signature. sites counts the dynamic calls of the function.
+func (b *flowBuilder) call(call ssa.CallInstruction, sites *int) {
+	common := call.Common()
+	value, _ := call.(ssa.Value)
+	if builtin, ok := common.Value.(*ssa.Builtin); ok {
+		if builtin.Name() == "recover" && value != nil {
+			b.flow(b.special("recover"), b.value(value))
+		}
+		return
+	}
+
+	var param, result func(i int, t types.Type) int
+	offset := 0
+	switch callee := common.StaticCallee(); {
+	case common.IsInvoke():
+		key := methodKey(common.Method)
+		param = func(i int, t types.Type) int {
+			return b.typed(flowNode{key: fmt.Sprintf("method %s param %d", key, i)}, t, false)
+		}
+		result = func(i int, t types.Type) int {
+			return b.add(flowNode{key: fmt.Sprintf("method %s result %d", key, i)}, t, false)
+		}
+		offset = 1
+		b.flow(b.value(common.Value), b.special(siteNode(call.Parent(), *sites)))
+		*sites++
+	case callee != nil:
+		param = func(i int, t types.Type) int {
+			return b.param(callee, i, t)
+		}
+		result = func(i int, t types.Type) int {
+			return b.result(callee, i, t)
+		}
+	default:
+		key := signatureKey(common.Signature())
+		param = func(i int, t types.Type) int {
+			return b.typed(flowNode{key: fmt.Sprintf("func %s param %d", key, i)}, t, false)
+		}
+		result = func(i int, t types.Type) int {
+			return b.add(flowNode{key: fmt.Sprintf("func %s result %d", key, i)}, t, false)
+		}
+	}
+
+	for i, arg := range common.Args {
+		b.alias(param(i+offset, arg.Type()), b.value(arg))
+	}
+
+	if value == nil {
+		// The results of go and defer calls are discarded.
+		return
+	}
+	results := common.Signature().Results()
+	if results.Len() == 1 {
+		b.flow(result(0, results.At(0).Type()), b.value(value))
+		return
+	}
+	for i := 0; i < results.Len(); i++ {
+		b.flow(result(i, results.At(i).Type()), b.indexed(value, i))
+	}
+}
+
+// method adds the flows between the parameters and results of the method fn
+// and the ones of its method key, as fn is a callee of the dynamic calls of
+// the method.
+func (b *flowBuilder) method(fn *ssa.Function) {
+	m, ok := fn.Object().(*types.Func)
+	if !ok {
+		return
+	}
+	key := methodKey(m)
+	b.link(fn, func(i int, t types.Type) int {
+		return b.typed(flowNode{key: fmt.Sprintf("method %s param %d", key, i+1)}, t, false)
+	}, func(i int, t types.Type) int {
+		return b.add(flowNode{key: fmt.Sprintf("method %s result %d", key, i)}, t, false)
+	})
+}
+
+// funcValue adds the flows between the parameters and results of the function
+// fn used as value and the ones of its signature, as fn is a callee of the
+// dynamic calls of functions with the signature.
+func (b *flowBuilder) funcValue(fn *ssa.Function) {
+	if fn.Signature.Recv() != nil {
+		return
+	}
+	key := signatureKey(fn.Signature)
+	b.link(fn, func(i int, t types.Type) int {
+		return b.typed(flowNode{key: fmt.Sprintf("func %s param %d", key, i)}, t, false)
+	}, func(i int, t types.Type) int {
+		return b.add(flowNode{key: fmt.Sprintf("func %s result %d", key, i)}, t, false)
+	})
+}
+
+// link adds the flows from the parameters of a dynamic call to the ones of
+// its callee fn (without the receiver) and from the results of fn to the ones
+// of the call.
+func (b *flowBuilder) link(fn *ssa.Function, param, result func(i int, t types.Type) int) {
+	if b.linked[fn] {
+		return
+	}
+	b.linked[fn] = true
+
+	offset := 0
+	if fn.Signature.Recv() != nil {
+		offset = 1
+	}
+	params := fn.Signature.Params()
+	for i := 0; i < params.Len(); i++ {
+		t := params.At(i).Type()
+		b.alias(b.param(fn, i+offset, t), param(i, t))
+	}
+	results := fn.Signature.Results()
+	for i := 0; i < results.Len(); i++ {
+		t := results.At(i).Type()
+		b.flow(b.result(fn, i, t), result(i, t))
+	}
+}
+
+// summary returns the flows into the nodes, which are visible to other
+// packages. The flows through the hidden nodes are replaced by direct flows.
+func (b *flowBuilder) summary() map[string]*typeFlow {
+	// The sets hold the indices of the visible nodes flowing into a node and
+	// the indices of the types, offset by the number of nodes. The visible
+	// nodes only flow their own index to their successors.
+	var typeNames []string
+	typeIDs := make(map[string]int)
+	succs := make([][]int, len(b.nodes))
+	initial := make([][]int, len(b.nodes))
+	for i := range b.nodes {
+		for name := range b.types[i] {
+			id, ok := typeIDs[name]
+			if !ok {
+				id = len(typeNames)
+				typeIDs[name] = id
+				typeNames = append(typeNames, name)
+			}
+			initial[i] = append(initial[i], len(b.nodes)+id)
+		}
+		for j := range b.succs[i] {
+			if b.visible(i) {
+				initial[j] = append(initial[j], i)
+			} else {
+				succs[i] = append(succs[i], j)
+			}
+		}
+	}
+
+	flows := make(map[string]*typeFlow)
+	for i, set := range reachingSets(succs, initial) {
+		if !b.visible(i) || len(set) == 0 {
+			continue
+		}
+		tf := &typeFlow{}
+		for _, id := range set {
+			if id < len(b.nodes) {
+				tf.From = append(tf.From, b.nodes[id].key)
+			} else {
+				tf.Types = append(tf.Types, typeNames[id-len(b.nodes)])
+			}
+		}
+		sort.Strings(tf.From)
+		sort.Strings(tf.Types)
+		flows[b.nodes[i].key] = tf
+	}
+	return flows
+}
+
+func (b *flowBuilder) visible(i int) bool {
+	return b.nodes[i].key != "" && !b.hidden[i]
+}
+
+// siteNode returns the node of the receiver of the ith dynamic call of fn.
+func siteNode(fn *ssa.Function, i int) string {
+	return fmt.Sprintf("site %s %d", fn, i)
+}
+
+// signatureKey identifies the signature sig without the names of the
+// parameters and results.
+func signatureKey(sig *types.Signature) string {
+	unnamedSig := types.NewSignatureType(nil, nil, nil, unnamed(sig.Params()), unnamed(sig.Results()), sig.Variadic())
+	return strings.TrimPrefix(types.TypeString(unnamedSig, nil), "func")
+}
+
+// interfaceUnderPtr returns the interface, if t is a (nested) pointer to an
+// interface, otherwise nil.
+func interfaceUnderPtr(t types.Type) types.Type {
+	seen := make(map[types.Type]bool)
+	for !seen[t] {
+		seen[t] = true
+		p, ok := t.Underlying().(*types.Pointer)
+		if !ok {
+			return nil
+		}
+		if types.IsInterface(p.Elem()) {
+			return p.Elem()
+		}
+		t = p.Elem()
+	}
+	return nil
+}
+
+// sliceArrayElem returns the element type of the (pointer to an) array or
+// slice t, otherwise nil.
+func sliceArrayElem(t types.Type) types.Type {
+	if p, ok := t.Underlying().(*types.Pointer); ok {
+		t = p.Elem()
+	}
+	switch u := t.Underlying().(type) {
+	case *types.Array:
+		return u.Elem()
+	case *types.Slice:
+		return u.Elem()
+	}
+	return nil
+}
+
+// reachingSets returns for every node of the graph with the successors succs
+// the union of the initial sets of the nodes reaching it, including its own.
+// The strongly connected components share their sets and are visited in
+// topological order.
+func reachingSets(succs [][]int, initial [][]int) [][]int {
+	n := len(succs)
+	index := make([]int, n)
+	low := make([]int, n)
+	component := make([]int, n)
+	onStack := make([]bool, n)
+	for i := range index {
+		index[i] = -1
+	}
+
+	// Tarjan's algorithm finds the components in reverse topological order.
+	var components [][]int
+	var stack []int
+	next := 0
+	var visit func(v int)
+	visit = func(v int) {
+		index[v], low[v] = next, next
+		next++
+		stack = append(stack, v)
+		onStack[v] = true
+		for _, w := range succs[v] {
+			if index[w] < 0 {
+				visit(w)
+				if low[w] < low[v] {
+					low[v] = low[w]
+				}
+			} else if onStack[w] && index[w] < low[v] {
+				low[v] = index[w]
+			}
+		}
+		if low[v] != index[v] {
+			return
+		}
+		var c []int
+		for {
+			w := stack[len(stack)-1]
+			stack = stack[:len(stack)-1]
+			onStack[w] = false
+			component[w] = len(components)
+			c = append(c, w)
+			if w == v {
+				break
+			}
+		}
+		components = append(components, c)
+	}
+	for v := 0; v < n; v++ {
+		if index[v] < 0 {
+			visit(v)
+		}
+	}
+
+	pending := make([]map[int]bool, len(components))
+	sets := make([][]int, n)
+	for c := len(components) - 1; c >= 0; c-- {
+		set := pending[c]
+		pending[c] = nil
+		if set == nil {
+			set = make(map[int]bool)
+		}
+		for _, v := range components[c] {
+			for _, x := range initial[v] {
+				set[x] = true
+			}
+		}
+		if len(set) == 0 {
+			continue
+		}
+		for _, v := range components[c] {
+			for _, w := range succs[v] {
+				d := component[w]
+				if d == c {
+					continue
+				}
+				if pending[d] == nil {
+					pending[d] = make(map[int]bool, len(set))
+				}
+				for x := range set {
+					pending[d][x] = true
+				}
+			}
+		}
+
+		sorted := make([]int, 0, len(set))
+		for x := range set {
+			sorted = append(sorted, x)
+		}
+		sort.Ints(sorted)
+		for _, v := range components[c] {
+			sets[v] = sorted
+		}
+	}
+	return sets
+}
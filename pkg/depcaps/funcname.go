@@ -0,0 +1,302 @@
+package depcaps
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/google/capslock/proto"
+)
+
+// FunctionName is a parsed function name, as used by capslock and
+// golang.org/x/tools/go/ssa, e.g.
+//
+//	gopkg.in/yaml.v3.Unmarshal
+//	(*example.com/pkg.T[example.com/other.U]).Method$1
+//	reflect.TypeFor[database/sql/driver.Valuer]
+type FunctionName struct {
+	// Package is the path of the package of the function. It is empty for
+	// methods of predeclared types, e.g. (error).Error.
+	Package string
+	// Receiver is the receiver type of a method without the package path,
+	// including the pointer and the type arguments, e.g. *T[example.com/other.U].
+	// It is empty for functions.
+	Receiver string
+	// Name is the name of the function or method, e.g. Method or init#1.
+	Name string
+	// TypeArgs are the type arguments of an instantiated generic function
+	// including the brackets, e.g. [database/sql/driver.Valuer].
+	TypeArgs string
+	// Closures are the indices of the closures, from the outermost to the
+	// innermost closure, e.g. [1 2] for F$1$2. They are empty for named
+	// functions.
+	Closures []int
+	// Wrapper is the kind of a synthetic wrapper function, either bound or
+	// thunk, and empty otherwise.
+	Wrapper string
+}
+
+// ParseFunctionName parses the function name s.
+func ParseFunctionName(s string) (FunctionName, error) {
+	var fn FunctionName
+	var rest string
+
+	if strings.HasPrefix(s, "(") {
+		end, err := matchingBracket(s, 0)
+		if err != nil {
+			return FunctionName{}, fmt.Errorf("invalid function name %q: %w", s, err)
+		}
+		if !strings.HasPrefix(s[end+1:], ".") {
+			return FunctionName{}, fmt.Errorf("invalid function name %q: expected . after receiver", s)
+		}
+		fn.Package, fn.Receiver, err = parseReceiver(s[1:end])
+		if err != nil {
+			return FunctionName{}, fmt.Errorf("invalid function name %q: %w", s, err)
+		}
+		rest = s[end+2:]
+	} else {
+		dot, err := lastTopLevelDot(s)
+		if err != nil {
+			return FunctionName{}, fmt.Errorf("invalid function name %q: %w", s, err)
+		}
+		if dot <= 0 {
+			return FunctionName{}, fmt.Errorf("invalid function name %q: missing package path", s)
+		}
+		fn.Package = s[:dot]
+		rest = s[dot+1:]
+	}
+
+	err := fn.parseName(rest)
+	if err != nil {
+		return FunctionName{}, fmt.Errorf("invalid function name %q: %w", s, err)
+	}
+
+	return fn, nil
+}
+
+// String returns the function name in the form parsed by ParseFunctionName.
+func (fn FunctionName) String() string {
+	var b strings.Builder
+	if fn.Receiver != "" {
+		b.WriteString("(")
+		typ := fn.Receiver
+		if strings.HasPrefix(typ, "*") {
+			b.WriteString("*")
+			typ = typ[1:]
+		}
+		if fn.Package != "" {
+			b.WriteString(fn.Package)
+			b.WriteString(".")
+		}
+		b.WriteString(typ)
+		b.WriteString(")")
+	} else {
+		b.WriteString(fn.Package)
+	}
+	b.WriteString(".")
+	b.WriteString(fn.Name)
+	b.WriteString(fn.TypeArgs)
+	for _, c := range fn.Closures {
+		b.WriteString("$")
+		b.WriteString(strconv.Itoa(c))
+	}
+	if fn.Wrapper != "" {
+		b.WriteString("$")
+		b.WriteString(fn.Wrapper)
+	}
+	return b.String()
+}
+
+// parseReceiver parses the receiver type recv, e.g. *example.com/pkg.T[int],
+// and returns the package path and the receiver type without the package
+// path.
+func parseReceiver(recv string) (pkg, typ string, err error) {
+	ptr := ""
+	if strings.HasPrefix(recv, "*") {
+		ptr = "*"
+		recv = recv[1:]
+	}
+
+	base, typeArgs := recv, ""
+	if i := strings.IndexByte(recv, '['); i >= 0 {
+		end, err := matchingBracket(recv, i)
+		if err != nil {
+			return "", "", err
+		}
+		if end != len(recv)-1 {
+			return "", "", fmt.Errorf("unexpected %q after type arguments of receiver", recv[end+1:])
+		}
+		base, typeArgs = recv[:i], recv[i:]
+	}
+
+	name := base
+	if dot := strings.LastIndexByte(base, '.'); dot >= 0 {
+		if dot == 0 {
+			return "", "", errors.New("missing package path of receiver")
+		}
+		pkg, name = base[:dot], base[dot+1:]
+	}
+	if !isIdentifier(name) {
+		return "", "", fmt.Errorf("invalid receiver type %q", name)
+	}
+
+	return pkg, ptr + name + typeArgs, nil
+}
+
+// parseName parses the name of the function following the package path or
+// the receiver, e.g. F[int]$1$2.
+func (fn *FunctionName) parseName(s string) error {
+	end := strings.IndexAny(s, "[$")
+	if end < 0 {
+		end = len(s)
+	}
+	fn.Name = s[:end]
+	if !isFunctionIdentifier(fn.Name) {
+		return fmt.Errorf("invalid function %q", fn.Name)
+	}
+
+	s = s[end:]
+	for s != "" {
+		switch {
+		case s[0] == '[':
+			if fn.TypeArgs != "" {
+				return errors.New("repeated type arguments")
+			}
+			end, err := matchingBracket(s, 0)
+			if err != nil {
+				return err
+			}
+			fn.TypeArgs, s = s[:end+1], s[end+1:]
+
+		case s[0] == '$':
+			if fn.Wrapper != "" {
+				return fmt.Errorf("unexpected %q after wrapper", s)
+			}
+			end := strings.IndexAny(s[1:], "[$") + 1
+			if end == 0 {
+				end = len(s)
+			}
+			suffix := s[1:end]
+			switch {
+			case suffix == "bound" || suffix == "thunk":
+				fn.Wrapper = suffix
+			case isDecimal(suffix):
+				index, err := strconv.Atoi(suffix)
+				if err != nil {
+					return fmt.Errorf("invalid closure index %q", suffix)
+				}
+				fn.Closures = append(fn.Closures, index)
+			default:
+				return fmt.Errorf("invalid suffix %q", "$"+suffix)
+			}
+			s = s[end:]
+
+		default:
+			return fmt.Errorf("unexpected %q", s)
+		}
+	}
+
+	return nil
+}
+
+// lastTopLevelDot returns the index of the last dot in s, which is not
+// enclosed in brackets, parentheses or braces, or -1.
+func lastTopLevelDot(s string) (int, error) {
+	dot := -1
+	depth := 0
+	for i := 0; i < len(s); i++ {
+		switch s[i] {
+		case '(', '[', '{':
+			depth++
+		case ')', ']', '}':
+			depth--
+			if depth < 0 {
+				return 0, fmt.Errorf("unbalanced %q", s[i])
+			}
+		case '.':
+			if depth == 0 {
+				dot = i
+			}
+		}
+	}
+	if depth != 0 {
+		return 0, errors.New("unbalanced brackets")
+	}
+	return dot, nil
+}
+
+// matchingBracket returns the index of the bracket closing the bracket at
+// index start of s. Brackets, parentheses and braces are considered.
+func matchingBracket(s string, start int) (int, error) {
+	var stack []byte
+	for i := start; i < len(s); i++ {
+		switch s[i] {
+		case '(':
+			stack = append(stack, ')')
+		case '[':
+			stack = append(stack, ']')
+		case '{':
+			stack = append(stack, '}')
+		case ')', ']', '}':
+			if len(stack) == 0 || stack[len(stack)-1] != s[i] {
+				return 0, fmt.Errorf("unbalanced %q", s[i])
+			}
+			stack = stack[:len(stack)-1]
+			if len(stack) == 0 {
+				return i, nil
+			}
+		}
+	}
+	return 0, fmt.Errorf("missing closing %q", stack[len(stack)-1])
+}
+
+func isIdentifier(s string) bool {
+	if s == "" {
+		return false
+	}
+	for i, r := range s {
+		if r == utf8.RuneError {
+			return false
+		}
+		if !unicode.IsLetter(r) && r != '_' && (i == 0 || !unicode.IsDigit(r)) {
+			return false
+		}
+	}
+	return true
+}
+
+// isFunctionIdentifier reports, if s is an identifier optionally followed by
+// #N, like the init functions of the files of a package, e.g. init#1.
+func isFunctionIdentifier(s string) bool {
+	name, index, ok := strings.Cut(s, "#")
+	return isIdentifier(name) && (!ok || isDecimal(index))
+}
+
+func isDecimal(s string) bool {
+	if s == "" {
+		return false
+	}
+	for _, r := range s {
+		if r < '0' || r > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+// packagePath returns the package path of fn. The package recorded with fn is
+// preferred, otherwise the package path is parsed from the name of fn, e.g.
+// for the functions of a capslock baseline file.
+func packagePath(fn *proto.Function) (string, error) {
+	if fn.GetPackage() != "" {
+		return fn.GetPackage(), nil
+	}
+	name, err := ParseFunctionName(fn.GetName())
+	if err != nil {
+		return "", err
+	}
+	return name.Package, nil
+}
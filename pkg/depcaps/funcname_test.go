@@ -0,0 +1,217 @@
+package depcaps_test
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/breml/depcaps/pkg/depcaps"
+)
+
+func TestParseFunctionName(t *testing.T) {
+	tt := []struct {
+		name string
+		in   string
+		want depcaps.FunctionName
+	}{
+		{
+			name: "function",
+			in:   "net/http.Get",
+			want: depcaps.FunctionName{Package: "net/http", Name: "Get"},
+		},
+		{
+			name: "function of a package path with dots",
+			in:   "gopkg.in/yaml.v3.Unmarshal",
+			want: depcaps.FunctionName{Package: "gopkg.in/yaml.v3", Name: "Unmarshal"},
+		},
+		{
+			name: "method",
+			in:   "(*net.TCPConn).Read",
+			want: depcaps.FunctionName{Package: "net", Receiver: "*TCPConn", Name: "Read"},
+		},
+		{
+			name: "method with value receiver of a package path with dots",
+			in:   "(gopkg.in/yaml.v3.Node).Decode",
+			want: depcaps.FunctionName{Package: "gopkg.in/yaml.v3", Receiver: "Node", Name: "Decode"},
+		},
+		{
+			name: "method of a predeclared type",
+			in:   "(error).Error",
+			want: depcaps.FunctionName{Receiver: "error", Name: "Error"},
+		},
+		{
+			name: "method of a generic type",
+			in:   "(*example.com/pkg.T[example.com/pkg2.U]).M",
+			want: depcaps.FunctionName{Package: "example.com/pkg", Receiver: "*T[example.com/pkg2.U]", Name: "M"},
+		},
+		{
+			name: "method of a generic type with multiple type arguments",
+			in:   "(example.com/pkg.Map[string,map[gopkg.in/yaml.v3.Kind]*example.com/pkg2.U]).Get",
+			want: depcaps.FunctionName{Package: "example.com/pkg", Receiver: "Map[string,map[gopkg.in/yaml.v3.Kind]*example.com/pkg2.U]", Name: "Get"},
+		},
+		{
+			name: "instantiated generic function",
+			in:   "reflect.TypeFor[database/sql/driver.Valuer]",
+			want: depcaps.FunctionName{Package: "reflect", Name: "TypeFor", TypeArgs: "[database/sql/driver.Valuer]"},
+		},
+		{
+			name: "instantiated generic function with function type argument",
+			in:   "example.com/pkg.Do[func(gopkg.in/yaml.v3.Node) error]",
+			want: depcaps.FunctionName{Package: "example.com/pkg", Name: "Do", TypeArgs: "[func(gopkg.in/yaml.v3.Node) error]"},
+		},
+		{
+			name: "closure",
+			in:   "example.com/pkg.F$1",
+			want: depcaps.FunctionName{Package: "example.com/pkg", Name: "F", Closures: []int{1}},
+		},
+		{
+			name: "nested closure of a method",
+			in:   "(*example.com/pkg.T).M$2$13",
+			want: depcaps.FunctionName{Package: "example.com/pkg", Receiver: "*T", Name: "M", Closures: []int{2, 13}},
+		},
+		{
+			name: "closure of an instantiated generic function",
+			in:   "example.com/pkg.F[int]$1",
+			want: depcaps.FunctionName{Package: "example.com/pkg", Name: "F", TypeArgs: "[int]", Closures: []int{1}},
+		},
+		{
+			name: "init function",
+			in:   "example.com/pkg.init#1",
+			want: depcaps.FunctionName{Package: "example.com/pkg", Name: "init#1"},
+		},
+		{
+			name: "closure of init",
+			in:   "example.com/pkg.init$1",
+			want: depcaps.FunctionName{Package: "example.com/pkg", Name: "init", Closures: []int{1}},
+		},
+		{
+			name: "bound method",
+			in:   "(*os.File).Write$bound",
+			want: depcaps.FunctionName{Package: "os", Receiver: "*File", Name: "Write", Wrapper: "bound"},
+		},
+		{
+			name: "method thunk",
+			in:   "(*os.File).Write$thunk",
+			want: depcaps.FunctionName{Package: "os", Receiver: "*File", Name: "Write", Wrapper: "thunk"},
+		},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := depcaps.ParseFunctionName(tc.in)
+			if err != nil {
+				t.Fatalf("expected no error, got: %v", err)
+			}
+			if !reflect.DeepEqual(tc.want, got) {
+				t.Fatalf("expected %#v, got %#v", tc.want, got)
+			}
+			if got.String() != tc.in {
+				t.Fatalf("expected String to return %q, got %q", tc.in, got.String())
+			}
+		})
+	}
+}
+
+func TestParseFunctionNameError(t *testing.T) {
+	tt := []struct {
+		name    string
+		in      string
+		wantErr string
+	}{
+		{
+			name:    "empty",
+			in:      "",
+			wantErr: "missing package path",
+		},
+		{
+			name:    "missing package path",
+			in:      "GetTime",
+			wantErr: "missing package path",
+		},
+		{
+			name:    "dot in type arguments only",
+			in:      "TypeFor[database/sql/driver.Valuer]",
+			wantErr: "missing package path",
+		},
+		{
+			name:    "missing closing parenthesis of receiver",
+			in:      "(*example.com/app.Config.Load",
+			wantErr: "missing closing ')'",
+		},
+		{
+			name:    "missing method",
+			in:      "(*example.com/app.Config)",
+			wantErr: "expected . after receiver",
+		},
+		{
+			name:    "missing function",
+			in:      "example.com/app.",
+			wantErr: `invalid function ""`,
+		},
+		{
+			name:    "unbalanced type arguments",
+			in:      "example.com/app.F[int",
+			wantErr: "unbalanced brackets",
+		},
+		{
+			name:    "invalid closure index",
+			in:      "example.com/app.F$x",
+			wantErr: `invalid suffix "$x"`,
+		},
+		{
+			name:    "invalid receiver type",
+			in:      "(example.com/app.T-1).M",
+			wantErr: `invalid receiver type "T-1"`,
+		},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := depcaps.ParseFunctionName(tc.in)
+			if err == nil {
+				t.Fatalf("expected error containing %q, got none", tc.wantErr)
+			}
+			if !strings.Contains(err.Error(), tc.wantErr) {
+				t.Fatalf("expected error containing %q, got: %v", tc.wantErr, err)
+			}
+		})
+	}
+}
+
+func FuzzParseFunctionName(f *testing.F) {
+	for _, seed := range []string{
+		"net/http.Get",
+		"gopkg.in/yaml.v3.Unmarshal",
+		"(*net.TCPConn).Read",
+		"(error).Error",
+		"(*example.com/pkg.T[example.com/pkg2.U]).M$1",
+		"reflect.TypeFor[database/sql/driver.Valuer]",
+		"example.com/pkg.F[int]$1$2",
+		"example.com/pkg.init#1",
+		"(*os.File).Write$bound",
+	} {
+		f.Add(seed)
+	}
+
+	f.Fuzz(func(t *testing.T, in string) {
+		fn, err := depcaps.ParseFunctionName(in)
+		if err != nil {
+			return
+		}
+		if fn.Name == "" {
+			t.Fatalf("expected name for %q", in)
+		}
+		if fn.Receiver == "" && fn.Package == "" {
+			t.Fatalf("expected package or receiver for %q", in)
+		}
+
+		// The canonical form must parse to the same function name.
+		again, err := depcaps.ParseFunctionName(fn.String())
+		if err != nil {
+			t.Fatalf("expected %q (from %q) to parse, got: %v", fn.String(), in, err)
+		}
+		if !reflect.DeepEqual(fn, again) {
+			t.Fatalf("expected %#v, got %#v for %q", fn, again, in)
+		}
+	})
+}
@@ -0,0 +1,235 @@
+package depcaps
+
+import (
+	"context"
+	"fmt"
+	"go/token"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/google/capslock/proto"
+	"golang.org/x/mod/modfile"
+	"golang.org/x/tools/go/analysis"
+	"golang.org/x/tools/go/packages"
+)
+
+// maxAffectedPackages is the maximum number of affected packages, which are
+// linked from a violation merged per module.
+const maxAffectedPackages = 3
+
+// goModFile returns the go.mod file of the main module in the file set of
+// pass, such that diagnostics can be reported at its directives. The file is
+// added to the file set on first use. Without go.mod, nil is returned.
+func (d *Linter) goModFile(pass *analysis.Pass) *token.File {
+	if d.modFile == nil {
+		return nil
+	}
+
+	// go.mod has been parsed by Init, a failed read is not expected and only
+	// prevents the reports at its directives.
+	return d.tokenFile(pass.Fset, d.modFile.Syntax.Name, func() ([]byte, error) {
+		return os.ReadFile(d.modFile.Syntax.Name)
+	})
+}
+
+// requirePos returns the position of the require directive of r in tf.
+func requirePos(tf *token.File, r *modfile.Require) token.Pos {
+	offset := r.Syntax.Start.Byte
+	if offset < 0 || offset > tf.Size() {
+		return token.NoPos
+	}
+	return tf.Pos(offset)
+}
+
+// approvalPackage returns the package of the main module, which reports the
+// unapproved modules. This is the package in the directory of go.mod or, if
+// there is none, the first package of the main module by path.
+func (d *Linter) approvalPackage(ctx context.Context) (string, error) {
+	pkgs, err := packages.Load(&packages.Config{
+		Context: ctx,
+		Mode:    packages.NeedName,
+		Dir:     filepath.Dir(d.modFile.Syntax.Name),
+		Env:     d.environ(),
+	}, "./...")
+	if err != nil {
+		return "", err
+	}
+
+	var first string
+	for _, pkg := range pkgs {
+		if pkg.PkgPath == d.mainModule {
+			return pkg.PkgPath, nil
+		}
+		if first == "" || pkg.PkgPath < first {
+			first = pkg.PkgPath
+		}
+	}
+	return first, nil
+}
+
+// unapprovedModules reports the required modules of the main module, which
+// are not listed in the config, if RequireExplicitApproval is set. The
+// modules are reported once by the approval package of the main module, such
+// that every driver reports them once.
+func (d *Linter) unapprovedModules(pass *analysis.Pass) []Finding {
+	if !d.RequireExplicitApproval || d.approval == "" || pass.Pkg.Path() != d.approval {
+		return nil
+	}
+	tf := d.goModFile(pass)
+	if tf == nil {
+		return nil
+	}
+
+	var findings []Finding
+	for _, r := range d.modFile.Require {
+		if r.Indirect && !d.RequireExplicitApprovalIndirect || d.approved(r.Mod.Path) {
+			continue
+		}
+
+		pos := requirePos(tf, r)
+		f := Finding{
+			Module:     r.Mod.Path,
+			Version:    r.Mod.Version,
+			Decision:   Violation,
+			Severity:   SeverityError,
+			Position:   pass.Fset.Position(pos),
+			Unapproved: true,
+		}
+		pass.Report(analysis.Diagnostic{
+			Pos:      pos,
+			Category: "unapproved",
+			Message:  f.message(),
+		})
+		findings = append(findings, f)
+	}
+
+	return findings
+}
+
+// approved reports, if the module modPath is listed in the config, that is
+// the module path or the path of a package of the module is a key of the
+// package settings.
+func (s *LinterSettings) approved(modPath string) bool {
+	modPath = s.packageKey(modPath)
+	listed := func(pkg string) bool {
+		return pkg == modPath || strings.HasPrefix(pkg, modPath+"/")
+	}
+
+	for pkg := range s.PackageAllowedCapabilities {
+		if listed(pkg) {
+			return true
+		}
+	}
+	for _, rules := range []map[string]map[string]Severity{s.PackageCapabilitySeverity, s.PackageDeniedCapabilities} {
+		for pkg := range rules {
+			if listed(pkg) {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+// moduleFindings merges the violations of the same capability of a module
+// into a single finding at the require directive of the module in go.mod.
+// The merged violations are kept in Affected. The allowed capabilities and
+// the violations of modules without require directive are kept as they are.
+func (d *Linter) moduleFindings(findings []Finding) []Finding {
+	if d.modFile == nil {
+		return findings
+	}
+
+	requires := make(map[string]*modfile.Require, len(d.modFile.Require))
+	for _, r := range d.modFile.Require {
+		requires[r.Mod.Path] = r
+	}
+
+	type moduleCapability struct {
+		module     string
+		capability proto.Capability
+	}
+	merged := make(map[moduleCapability]int)
+	result := make([]Finding, 0, len(findings))
+	for _, f := range findings {
+		r, ok := requires[f.Module]
+		if !ok || f.Decision != Violation || f.Unapproved {
+			result = append(result, f)
+			continue
+		}
+
+		k := moduleCapability{module: f.Module, capability: f.Capability}
+		i, ok := merged[k]
+		if !ok {
+			i = len(result)
+			merged[k] = i
+			result = append(result, Finding{
+				Module:     f.Module,
+				Version:    f.Version,
+				Capability: f.Capability,
+				Decision:   Violation,
+				Position: token.Position{
+					Filename: d.modFile.Syntax.Name,
+					Offset:   r.Syntax.Start.Byte,
+					Line:     r.Syntax.Start.Line,
+					Column:   r.Syntax.Start.LineRune,
+				},
+			})
+		}
+		m := &result[i]
+		if f.Severity > m.Severity {
+			m.Severity = f.Severity
+		}
+		m.Affected = append(m.Affected, f)
+	}
+
+	return result
+}
+
+// affectedPackages returns the first of the affected findings of every
+// package of the own module.
+func (f Finding) affectedPackages() []Finding {
+	seen := make(map[string]bool)
+	var pkgs []Finding
+	for _, a := range f.Affected {
+		if !seen[a.Package] {
+			seen[a.Package] = true
+			pkgs = append(pkgs, a)
+		}
+	}
+	return pkgs
+}
+
+// expandModuleFindings returns findings with the merged findings of the
+// modules replaced by the findings of the affected packages.
+func expandModuleFindings(findings []Finding) []Finding {
+	expanded := make([]Finding, 0, len(findings))
+	for _, f := range findings {
+		if len(f.Affected) > 0 {
+			expanded = append(expanded, f.Affected...)
+			continue
+		}
+		expanded = append(expanded, f)
+	}
+	return expanded
+}
+
+// message returns the message of the violation f without the severity.
+func (f Finding) message() string {
+	if f.Problem != "" {
+		return f.Problem
+	}
+	if f.Unapproved {
+		return fmt.Sprintf("Module %s is not approved by the config", f.Module)
+	}
+	if len(f.Affected) > 0 {
+		n := len(f.affectedPackages())
+		packages := "packages"
+		if n == 1 {
+			packages = "package"
+		}
+		return fmt.Sprintf("Module %s has not allowed capability %s, reached by %d %s", f.Module, f.Capability, n, packages)
+	}
+	return fmt.Sprintf("Package %s has not allowed capability %s", f.Dependency, f.Capability)
+}
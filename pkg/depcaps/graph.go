@@ -0,0 +1,268 @@
+package depcaps
+
+import (
+	"fmt"
+	"io"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// Graph is the fragment of the call graph, which is formed by the call paths
+// of a set of findings.
+type Graph struct {
+	nodes map[string]*graphNode
+	edges map[graphEdge]bool
+}
+
+type graphNode struct {
+	id           string
+	function     string
+	pkg          string
+	module       string
+	capabilities map[string]bool
+}
+
+type graphEdge struct {
+	from, to string
+	site     string
+}
+
+// NewGraph returns the graph of all the call paths of findings.
+func NewGraph(findings []Finding) *Graph {
+	g := &Graph{
+		nodes: make(map[string]*graphNode),
+		edges: make(map[graphEdge]bool),
+	}
+
+	for _, f := range expandModuleFindings(findings) {
+		paths := f.Paths
+		if len(paths) == 0 {
+			paths = [][]CallSite{f.Path}
+		}
+		capability := f.Capability.String()
+		if f.Severity != SeverityNone {
+			capability = fmt.Sprintf("%s (%s)", capability, f.Severity)
+		}
+		for _, path := range paths {
+			g.addPath(capability, path)
+		}
+	}
+
+	// The ids of the nodes are assigned in the order of the function names,
+	// such that the output is reproducible.
+	for i, n := range g.sortedNodes() {
+		n.id = fmt.Sprintf("n%d", i)
+	}
+
+	return g
+}
+
+func (g *Graph) addPath(capability string, path []CallSite) {
+	for i, cs := range path {
+		n, ok := g.nodes[cs.Function]
+		if !ok {
+			n = &graphNode{
+				function:     cs.Function,
+				pkg:          cs.Package,
+				module:       cs.Module,
+				capabilities: make(map[string]bool),
+			}
+			g.nodes[cs.Function] = n
+		}
+		if i == len(path)-1 {
+			n.capabilities[capability] = true
+		}
+		if i > 0 {
+			g.edges[graphEdge{from: path[i-1].Function, to: cs.Function, site: siteLabel(cs)}] = true
+		}
+	}
+}
+
+// siteLabel returns the call site of cs in the form file:line.
+func siteLabel(cs CallSite) string {
+	if !cs.Position.IsValid() {
+		return ""
+	}
+	return fmt.Sprintf("%s:%d", filepath.Base(cs.Position.Filename), cs.Position.Line)
+}
+
+func (g *Graph) sortedNodes() []*graphNode {
+	nodes := make([]*graphNode, 0, len(g.nodes))
+	for _, n := range g.nodes {
+		nodes = append(nodes, n)
+	}
+	sort.Slice(nodes, func(i, j int) bool {
+		return nodes[i].function < nodes[j].function
+	})
+	return nodes
+}
+
+func (g *Graph) sortedEdges() []graphEdge {
+	edges := make([]graphEdge, 0, len(g.edges))
+	for e := range g.edges {
+		edges = append(edges, e)
+	}
+	sort.Slice(edges, func(i, j int) bool {
+		a, b := edges[i], edges[j]
+		if a.from != b.from {
+			return a.from < b.from
+		}
+		if a.to != b.to {
+			return a.to < b.to
+		}
+		return a.site < b.site
+	})
+	return edges
+}
+
+// graphModule holds the nodes of a module grouped by package.
+type graphModule struct {
+	name     string
+	packages []graphPackage
+}
+
+type graphPackage struct {
+	name  string
+	nodes []*graphNode
+}
+
+// clusters returns the nodes grouped by module and package, both sorted by
+// name.
+func (g *Graph) clusters() []graphModule {
+	var modules []graphModule
+	nodes := g.sortedNodes()
+	sort.SliceStable(nodes, func(i, j int) bool {
+		if nodes[i].module != nodes[j].module {
+			return nodes[i].module < nodes[j].module
+		}
+		return nodes[i].pkg < nodes[j].pkg
+	})
+
+	for _, n := range nodes {
+		module := n.module
+		if module == "" {
+			module = "std"
+		}
+		if len(modules) == 0 || modules[len(modules)-1].name != module {
+			modules = append(modules, graphModule{name: module})
+		}
+		m := &modules[len(modules)-1]
+		if len(m.packages) == 0 || m.packages[len(m.packages)-1].name != n.pkg {
+			m.packages = append(m.packages, graphPackage{name: n.pkg})
+		}
+		p := &m.packages[len(m.packages)-1]
+		p.nodes = append(p.nodes, n)
+	}
+
+	return modules
+}
+
+// label returns the label of n. The package is omitted, since the node is
+// part of the cluster of the package. Functions with capabilities are
+// labeled with their capabilities and, for violations, the severity.
+func (n *graphNode) label() string {
+	label := n.function
+	if n.pkg != "" {
+		label = strings.Replace(label, n.pkg+".", "", 1)
+	}
+
+	caps := make([]string, 0, len(n.capabilities))
+	for c := range n.capabilities {
+		caps = append(caps, c)
+	}
+	sort.Strings(caps)
+	if len(caps) > 0 {
+		label += "\n" + strings.Join(caps, "\n")
+	}
+
+	return label
+}
+
+// WriteDOT writes the graph in the Graphviz DOT language to w. The functions
+// are clustered by module and package, the edges are labeled with the call
+// sites.
+func (g *Graph) WriteDOT(w io.Writer) error {
+	var b strings.Builder
+	b.WriteString("digraph depcaps {\n")
+	b.WriteString("  rankdir=LR;\n")
+	b.WriteString("  node [shape=box];\n")
+
+	for i, m := range g.clusters() {
+		fmt.Fprintf(&b, "  subgraph cluster_%d {\n", i)
+		fmt.Fprintf(&b, "    label=%s;\n", dotQuote(m.name))
+		for j, p := range m.packages {
+			fmt.Fprintf(&b, "    subgraph cluster_%d_%d {\n", i, j)
+			fmt.Fprintf(&b, "      label=%s;\n", dotQuote(p.name))
+			for _, n := range p.nodes {
+				style := ""
+				if len(n.capabilities) > 0 {
+					style = ", style=filled, fillcolor=\"#f4cccc\""
+				}
+				fmt.Fprintf(&b, "      %s [label=%s%s];\n", n.id, dotQuote(n.label()), style)
+			}
+			b.WriteString("    }\n")
+		}
+		b.WriteString("  }\n")
+	}
+
+	for _, e := range g.sortedEdges() {
+		label := ""
+		if e.site != "" {
+			label = fmt.Sprintf(" [label=%s]", dotQuote(e.site))
+		}
+		fmt.Fprintf(&b, "  %s -> %s%s;\n", g.nodes[e.from].id, g.nodes[e.to].id, label)
+	}
+
+	b.WriteString("}\n")
+
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+func dotQuote(s string) string {
+	s = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`).Replace(s)
+	return `"` + s + `"`
+}
+
+// WriteMermaid writes the graph as Mermaid flowchart to w. The functions are
+// grouped by module and package in subgraphs, the edges are labeled with the
+// call sites.
+func (g *Graph) WriteMermaid(w io.Writer) error {
+	var b strings.Builder
+	b.WriteString("flowchart LR\n")
+
+	for i, m := range g.clusters() {
+		fmt.Fprintf(&b, "  subgraph m%d[%s]\n", i, mermaidQuote(m.name))
+		for j, p := range m.packages {
+			fmt.Fprintf(&b, "    subgraph m%d_%d[%s]\n", i, j, mermaidQuote(p.name))
+			for _, n := range p.nodes {
+				fmt.Fprintf(&b, "      %s[%s]\n", n.id, mermaidQuote(n.label()))
+			}
+			b.WriteString("    end\n")
+		}
+		b.WriteString("  end\n")
+	}
+
+	for _, e := range g.sortedEdges() {
+		label := ""
+		if e.site != "" {
+			label = fmt.Sprintf("|%s|", mermaidQuote(e.site))
+		}
+		fmt.Fprintf(&b, "  %s -->%s %s\n", g.nodes[e.from].id, label, g.nodes[e.to].id)
+	}
+
+	for _, n := range g.sortedNodes() {
+		if len(n.capabilities) > 0 {
+			fmt.Fprintf(&b, "  style %s fill:#f4cccc\n", n.id)
+		}
+	}
+
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+func mermaidQuote(s string) string {
+	s = strings.NewReplacer(`"`, "#quot;", "\n", "<br>").Replace(s)
+	return `"` + s + `"`
+}
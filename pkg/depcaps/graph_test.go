@@ -0,0 +1,84 @@
+package depcaps_test
+
+import (
+	"bytes"
+	"go/token"
+	"strings"
+	"testing"
+
+	"github.com/google/capslock/proto"
+
+	"github.com/breml/depcaps/pkg/depcaps"
+)
+
+func testFindings() []depcaps.Finding {
+	path := []depcaps.CallSite{
+		{Function: "example.com/app.main", Package: "example.com/app", Module: "example.com/app"},
+		{
+			Function: "github.com/google/uuid.GetTime",
+			Package:  "github.com/google/uuid",
+			Module:   "github.com/google/uuid",
+			Position: token.Position{Filename: "/src/app/main.go", Line: 12, Column: 14},
+		},
+		{
+			Function: "(*net.Resolver).LookupHost",
+			Package:  "net",
+			Position: token.Position{Filename: "/go/pkg/mod/github.com/google/uuid@v1.3.1/time.go", Line: 48, Column: 16},
+		},
+	}
+
+	return []depcaps.Finding{
+		{
+			Package:    "example.com/app",
+			Dependency: "github.com/google/uuid",
+			Capability: proto.Capability_CAPABILITY_NETWORK,
+			Path:       path,
+			Paths:      [][]depcaps.CallSite{path},
+		},
+	}
+}
+
+func TestGraphDOT(t *testing.T) {
+	var buf bytes.Buffer
+	err := depcaps.NewGraph(testFindings()).WriteDOT(&buf)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	got := buf.String()
+
+	for _, want := range []string{
+		"digraph depcaps {",
+		`label="github.com/google/uuid";`,
+		`label="std";`,
+		`[label="GetTime"];`,
+		`[label="(*Resolver).LookupHost\nCAPABILITY_NETWORK", style=filled`,
+		`[label="main.go:12"];`,
+		`[label="time.go:48"];`,
+	} {
+		if !strings.Contains(got, want) {
+			t.Fatalf("expected DOT to contain %q, got:\n%s", want, got)
+		}
+	}
+}
+
+func TestGraphMermaid(t *testing.T) {
+	var buf bytes.Buffer
+	err := depcaps.NewGraph(testFindings()).WriteMermaid(&buf)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	got := buf.String()
+
+	for _, want := range []string{
+		"flowchart LR\n",
+		`["github.com/google/uuid"]`,
+		`["(*Resolver).LookupHost<br>CAPABILITY_NETWORK"]`,
+		`-->|"main.go:12"|`,
+		`-->|"time.go:48"|`,
+		"fill:#f4cccc",
+	} {
+		if !strings.Contains(got, want) {
+			t.Fatalf("expected Mermaid to contain %q, got:\n%s", want, got)
+		}
+	}
+}
@@ -0,0 +1,219 @@
+package depcaps
+
+import (
+	"encoding/json"
+	"go/token"
+	"io"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+)
+
+// ReportVersion is the version of the schema of Report. It is increased with
+// every incompatible change of the schema.
+const ReportVersion = 1
+
+// Report holds the capabilities of the dependencies grouped by module,
+// package and capability. It is the schema of the JSON output.
+type Report struct {
+	Version int            `json:"version"`
+	Modules []ModuleReport `json:"modules"`
+	// Problems are the problems of the analysis, which are not capabilities,
+	// e.g. malformed or unused //depcaps:allow directives.
+	Problems []ProblemReport `json:"problems,omitempty"`
+
+	// Findings are the findings, the report is made of. They are not part of
+	// the JSON output.
+	Findings []Finding `json:"-"`
+}
+
+// Violations returns the findings of the report, which are not allowed.
+func (r *Report) Violations() []Finding {
+	return Violations(r.Findings)
+}
+
+// ModuleReport holds the capabilities of the packages of a dependency module.
+type ModuleReport struct {
+	Path    string `json:"path"`
+	Version string `json:"version,omitempty"`
+	// Unapproved is true, if the module is not listed in the config, while
+	// RequireExplicitApproval is set.
+	Unapproved bool            `json:"unapproved,omitempty"`
+	Packages   []PackageReport `json:"packages"`
+}
+
+// ProblemReport is a problem of the analysis of a package of the own module.
+type ProblemReport struct {
+	Package  string   `json:"package"`
+	Severity Severity `json:"severity"`
+	// Position is the position of the problem in the form file:line:column,
+	// relative to the base directory like the positions of ReachReport.
+	Position string `json:"position,omitempty"`
+	Message  string `json:"message"`
+}
+
+// PackageReport holds the capabilities of a dependency package.
+type PackageReport struct {
+	Path         string             `json:"path"`
+	Capabilities []CapabilityReport `json:"capabilities"`
+}
+
+// CapabilityReport holds a capability of a dependency package, the decision,
+// if the capability is allowed, and the packages of the own module reaching
+// the capability. The capability is a violation, if it is a violation for any
+// of the reaching packages, the severity is the highest severity of the
+// reaching packages.
+type CapabilityReport struct {
+	Capability string        `json:"capability"`
+	Decision   Decision      `json:"decision"`
+	Severity   Severity      `json:"severity,omitempty"`
+	ReachedBy  []ReachReport `json:"reachedBy"`
+}
+
+// ReachReport is a package of the own module, which reaches a capability.
+type ReachReport struct {
+	Package string `json:"package"`
+	// Decision is the decision for Package. It only differs between the
+	// packages for capabilities covered by the baseline.
+	Decision Decision `json:"decision"`
+	// Severity is the severity of a violation, it is omitted for allowed
+	// capabilities.
+	Severity Severity `json:"severity,omitempty"`
+	// Position is the call site or import in Package in the form
+	// file:line:column. Files in the base directory of the report are
+	// relative to the base directory.
+	Position string `json:"position,omitempty"`
+	// CallPath holds the functions of the call path, through which the
+	// capability is reached, starting with the function in Package.
+	CallPath []string `json:"callPath,omitempty"`
+}
+
+// NewReport returns the report for the findings. Positions in baseDir are
+// relative to baseDir.
+func NewReport(baseDir string, findings []Finding) (*Report, error) {
+	baseDir, err := filepath.Abs(baseDir)
+	if err != nil {
+		return nil, err
+	}
+
+	type capabilityKey struct {
+		module, pkg, capability string
+	}
+	grouped := make(map[capabilityKey][]Finding)
+	unapproved := make(map[string]string)
+	// The report is grouped by capability anyway, so the violations merged
+	// per module are reported per affected package.
+	var problems []ProblemReport
+	for _, f := range expandModuleFindings(findings) {
+		if f.Problem != "" {
+			problems = append(problems, ProblemReport{
+				Package:  f.Package,
+				Severity: f.Severity,
+				Position: relativePosition(baseDir, f.Position),
+				Message:  f.Problem,
+			})
+			continue
+		}
+		if f.Unapproved {
+			unapproved[f.Module] = f.Version
+			continue
+		}
+		k := capabilityKey{module: f.Module, pkg: f.Dependency, capability: f.Capability.String()}
+		grouped[k] = append(grouped[k], f)
+	}
+
+	keys := make([]capabilityKey, 0, len(grouped))
+	for k := range grouped {
+		keys = append(keys, k)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if keys[i].module != keys[j].module {
+			return keys[i].module < keys[j].module
+		}
+		if keys[i].pkg != keys[j].pkg {
+			return keys[i].pkg < keys[j].pkg
+		}
+		return keys[i].capability < keys[j].capability
+	})
+
+	report := &Report{
+		Version:  ReportVersion,
+		Modules:  []ModuleReport{},
+		Problems: problems,
+		Findings: findings,
+	}
+	for _, k := range keys {
+		fs := grouped[k]
+
+		if n := len(report.Modules); n == 0 || report.Modules[n-1].Path != k.module {
+			report.Modules = append(report.Modules, ModuleReport{Path: k.module, Version: fs[0].Version})
+		}
+		mod := &report.Modules[len(report.Modules)-1]
+
+		if n := len(mod.Packages); n == 0 || mod.Packages[n-1].Path != k.pkg {
+			mod.Packages = append(mod.Packages, PackageReport{Path: k.pkg})
+		}
+		pkg := &mod.Packages[len(mod.Packages)-1]
+
+		capability := CapabilityReport{
+			Capability: k.capability,
+			Decision:   fs[0].Decision,
+		}
+		for _, f := range fs {
+			if f.Decision == Violation {
+				capability.Decision = Violation
+			}
+			if f.Severity > capability.Severity {
+				capability.Severity = f.Severity
+			}
+
+			reach := ReachReport{
+				Package:  f.Package,
+				Decision: f.Decision,
+				Severity: f.Severity,
+				Position: relativePosition(baseDir, f.Position),
+			}
+			for _, cs := range f.Path {
+				reach.CallPath = append(reach.CallPath, cs.Function)
+			}
+			capability.ReachedBy = append(capability.ReachedBy, reach)
+		}
+		sort.SliceStable(capability.ReachedBy, func(i, j int) bool {
+			return capability.ReachedBy[i].Package < capability.ReachedBy[j].Package
+		})
+
+		pkg.Capabilities = append(pkg.Capabilities, capability)
+	}
+
+	for path, version := range unapproved {
+		i := sort.Search(len(report.Modules), func(i int) bool {
+			return report.Modules[i].Path >= path
+		})
+		if i == len(report.Modules) || report.Modules[i].Path != path {
+			report.Modules = slices.Insert(report.Modules, i, ModuleReport{Path: path, Version: version, Packages: []PackageReport{}})
+		}
+		report.Modules[i].Unapproved = true
+	}
+
+	return report, nil
+}
+
+// WriteJSON writes the report as indented JSON to w.
+func (r *Report) WriteJSON(w io.Writer) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(r)
+}
+
+// relativePosition returns pos in the form file:line:column with the file
+// relative to baseDir, if the file is located in baseDir.
+func relativePosition(baseDir string, pos token.Position) string {
+	if !pos.IsValid() {
+		return ""
+	}
+	if rel, err := filepath.Rel(baseDir, pos.Filename); err == nil && !strings.HasPrefix(rel, "..") {
+		pos.Filename = filepath.ToSlash(rel)
+	}
+	return pos.String()
+}
@@ -0,0 +1,80 @@
+package depcaps_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"reflect"
+	"testing"
+
+	"github.com/breml/depcaps/pkg/depcaps"
+)
+
+func TestReport(t *testing.T) {
+	testCaseDir := chdirTestdata(t, "alltest")
+
+	settings := &depcaps.LinterSettings{
+		GlobalAllowedCapabilities: map[string]bool{
+			"CAPABILITY_REFLECT": true,
+		},
+	}
+	findings, err := newLinter(t, settings, depcaps.WithCacheDir(t.TempDir())).Analyze(context.Background(), testCaseDir, "./simple/...")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	report, err := depcaps.NewReport(testCaseDir, findings)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	var buf bytes.Buffer
+	err = report.WriteJSON(&buf)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	var got depcaps.Report
+	err = json.Unmarshal(buf.Bytes(), &got)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	// The findings are not part of the JSON output.
+	report.Findings = nil
+	if !reflect.DeepEqual(*report, got) {
+		t.Fatalf("expected report to survive a JSON round trip, got:\n%s", buf.String())
+	}
+
+	if got.Version != depcaps.ReportVersion {
+		t.Fatalf("expected version %d, got: %d", depcaps.ReportVersion, got.Version)
+	}
+	if len(got.Modules) != 1 || got.Modules[0].Path != "github.com/google/uuid" || got.Modules[0].Version != "v1.3.1" {
+		t.Fatalf("expected module github.com/google/uuid@v1.3.1, got: %+v", got.Modules)
+	}
+	if len(got.Modules[0].Packages) != 1 || got.Modules[0].Packages[0].Path != "github.com/google/uuid" {
+		t.Fatalf("expected package github.com/google/uuid, got: %+v", got.Modules[0].Packages)
+	}
+
+	decisions := make(map[string]depcaps.Decision)
+	reachedBy := make(map[string][]string)
+	for _, c := range got.Modules[0].Packages[0].Capabilities {
+		decisions[c.Capability] = c.Decision
+		for _, r := range c.ReachedBy {
+			reachedBy[c.Capability] = append(reachedBy[c.Capability], r.Package)
+		}
+	}
+
+	wantDecisions := map[string]depcaps.Decision{
+		"CAPABILITY_FILES":   depcaps.Violation,
+		"CAPABILITY_NETWORK": depcaps.Violation,
+		"CAPABILITY_REFLECT": depcaps.AllowedGlobal,
+	}
+	if !reflect.DeepEqual(wantDecisions, decisions) {
+		t.Fatalf("expected decisions %v, got: %v", wantDecisions, decisions)
+	}
+
+	wantReachedBy := []string{"alltest/simple/function", "alltest/simple/method"}
+	if !reflect.DeepEqual(wantReachedBy, reachedBy["CAPABILITY_REFLECT"]) {
+		t.Fatalf("expected CAPABILITY_REFLECT to be reached by %v, got: %v", wantReachedBy, reachedBy["CAPABILITY_REFLECT"])
+	}
+}
@@ -0,0 +1,148 @@
+package depcaps
+
+import (
+	"fmt"
+	"io"
+	"sort"
+)
+
+// ModuleDiff is the change of a dependency module and its capabilities
+// between two states of the dependencies, e.g. before and after an update of
+// go.mod and go.sum.
+type ModuleDiff struct {
+	Path string `json:"path"`
+	// OldVersion is empty for a module, which is only a dependency in the new
+	// state, NewVersion is empty for a module, which is no longer a
+	// dependency.
+	OldVersion string `json:"oldVersion,omitempty"`
+	NewVersion string `json:"newVersion,omitempty"`
+	// Added are the capabilities of the module, which are only reached in the
+	// new state, Removed the ones only reached in the old state.
+	Added   []string `json:"added,omitempty"`
+	Removed []string `json:"removed,omitempty"`
+}
+
+// DiffModules compares the capabilities of the dependency modules reached in
+// oldFindings and newFindings, which are the results of Analyze. The versions
+// of the modules without any capability are taken from oldVersions and
+// newVersions, which are keyed by module path and might be nil. Only the
+// modules with a changed version or changed capabilities are returned, sorted
+// by path.
+func DiffModules(oldFindings, newFindings []Finding, oldVersions, newVersions map[string]string) []ModuleDiff {
+	oldModules := moduleCapabilities(oldFindings, oldVersions)
+	newModules := moduleCapabilities(newFindings, newVersions)
+
+	paths := make(map[string]struct{}, len(oldModules)+len(newModules))
+	for path := range oldModules {
+		paths[path] = struct{}{}
+	}
+	for path := range newModules {
+		paths[path] = struct{}{}
+	}
+
+	var diffs []ModuleDiff
+	for path := range paths {
+		oldModule, newModule := oldModules[path], newModules[path]
+		diff := ModuleDiff{
+			Path:       path,
+			OldVersion: oldModule.version,
+			NewVersion: newModule.version,
+			Added:      missingCapabilities(newModule.capabilities, oldModule.capabilities),
+			Removed:    missingCapabilities(oldModule.capabilities, newModule.capabilities),
+		}
+		if diff.OldVersion == diff.NewVersion && len(diff.Added) == 0 && len(diff.Removed) == 0 {
+			continue
+		}
+		diffs = append(diffs, diff)
+	}
+
+	sort.Slice(diffs, func(i, j int) bool {
+		return diffs[i].Path < diffs[j].Path
+	})
+
+	return diffs
+}
+
+// WriteModuleDiffs writes the module diffs in a human readable form to w, one
+// module per line followed by the added and the removed capabilities.
+func WriteModuleDiffs(w io.Writer, diffs []ModuleDiff) error {
+	for _, diff := range diffs {
+		_, err := fmt.Fprintf(w, "%s %s => %s\n", diff.Path, versionOrNone(diff.OldVersion), versionOrNone(diff.NewVersion))
+		if err != nil {
+			return err
+		}
+		if len(diff.Added) == 0 && len(diff.Removed) == 0 {
+			_, err = fmt.Fprintln(w, "\tno capability changes")
+			if err != nil {
+				return err
+			}
+		}
+		for _, c := range diff.Added {
+			_, err = fmt.Fprintf(w, "\t+ %s\n", c)
+			if err != nil {
+				return err
+			}
+		}
+		for _, c := range diff.Removed {
+			_, err = fmt.Fprintf(w, "\t- %s\n", c)
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// moduleState is the version and the reached capabilities of a dependency
+// module.
+type moduleState struct {
+	version      string
+	capabilities map[string]struct{}
+}
+
+func moduleCapabilities(findings []Finding, versions map[string]string) map[string]moduleState {
+	modules := make(map[string]moduleState, len(versions))
+	for path, version := range versions {
+		modules[path] = moduleState{version: version, capabilities: map[string]struct{}{}}
+	}
+
+	for _, f := range findings {
+		if f.Module == "" || f.Unapproved {
+			continue
+		}
+		m, ok := modules[f.Module]
+		if !ok {
+			m = moduleState{capabilities: map[string]struct{}{}}
+		}
+		// The version of the analysis is the selected version, which takes
+		// precedence over the required version.
+		if f.Version != "" {
+			m.version = f.Version
+		}
+		m.capabilities[f.Capability.String()] = struct{}{}
+		modules[f.Module] = m
+	}
+
+	return modules
+}
+
+// missingCapabilities returns the capabilities of a, which are not in b,
+// sorted by name.
+func missingCapabilities(a, b map[string]struct{}) []string {
+	var missing []string
+	for c := range a {
+		if _, ok := b[c]; !ok {
+			missing = append(missing, c)
+		}
+	}
+	sort.Strings(missing)
+	return missing
+}
+
+func versionOrNone(version string) string {
+	if version == "" {
+		return "(none)"
+	}
+	return version
+}
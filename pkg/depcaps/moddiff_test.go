@@ -0,0 +1,82 @@
+package depcaps_test
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+
+	"github.com/google/capslock/proto"
+
+	"github.com/breml/depcaps/pkg/depcaps"
+)
+
+func TestDiffModules(t *testing.T) {
+	finding := func(module, version string, c proto.Capability) depcaps.Finding {
+		return depcaps.Finding{
+			Package:    "example.com/app",
+			Dependency: module,
+			Module:     module,
+			Version:    version,
+			Capability: c,
+		}
+	}
+
+	oldFindings := []depcaps.Finding{
+		finding("github.com/google/uuid", "v1.3.0", proto.Capability_CAPABILITY_FILES),
+		finding("github.com/google/uuid", "v1.3.0", proto.Capability_CAPABILITY_READ_SYSTEM_STATE),
+		finding("example.com/removed", "v1.0.0", proto.Capability_CAPABILITY_EXEC),
+		finding("example.com/unchanged", "v1.0.0", proto.Capability_CAPABILITY_NETWORK),
+	}
+	newFindings := []depcaps.Finding{
+		finding("github.com/google/uuid", "v1.3.1", proto.Capability_CAPABILITY_NETWORK),
+		finding("github.com/google/uuid", "v1.3.1", proto.Capability_CAPABILITY_READ_SYSTEM_STATE),
+		finding("example.com/added", "v0.1.0", proto.Capability_CAPABILITY_REFLECT),
+		finding("example.com/unchanged", "v1.0.0", proto.Capability_CAPABILITY_NETWORK),
+	}
+	oldVersions := map[string]string{
+		"example.com/quiet":   "v1.0.0",
+		"example.com/removed": "v0.9.0",
+	}
+	newVersions := map[string]string{
+		"example.com/quiet":     "v1.1.0",
+		"example.com/unchanged": "v1.0.0",
+	}
+
+	got := depcaps.DiffModules(oldFindings, newFindings, oldVersions, newVersions)
+
+	want := []depcaps.ModuleDiff{
+		{Path: "example.com/added", NewVersion: "v0.1.0", Added: []string{"CAPABILITY_REFLECT"}},
+		{Path: "example.com/quiet", OldVersion: "v1.0.0", NewVersion: "v1.1.0"},
+		{Path: "example.com/removed", OldVersion: "v1.0.0", Removed: []string{"CAPABILITY_EXEC"}},
+		{
+			Path:       "github.com/google/uuid",
+			OldVersion: "v1.3.0",
+			NewVersion: "v1.3.1",
+			Added:      []string{"CAPABILITY_NETWORK"},
+			Removed:    []string{"CAPABILITY_FILES"},
+		},
+	}
+	if !reflect.DeepEqual(want, got) {
+		t.Fatalf("expected %+v, got %+v", want, got)
+	}
+
+	var buf bytes.Buffer
+	err := depcaps.WriteModuleDiffs(&buf, got)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	wantText := `example.com/added (none) => v0.1.0
+	+ CAPABILITY_REFLECT
+example.com/quiet v1.0.0 => v1.1.0
+	no capability changes
+example.com/removed v1.0.0 => (none)
+	- CAPABILITY_EXEC
+github.com/google/uuid v1.3.0 => v1.3.1
+	+ CAPABILITY_NETWORK
+	- CAPABILITY_FILES
+`
+	if buf.String() != wantText {
+		t.Fatalf("expected:\n%s\ngot:\n%s", wantText, buf.String())
+	}
+}
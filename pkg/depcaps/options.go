@@ -0,0 +1,142 @@
+package depcaps
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Option configures a Linter created with New.
+type Option func(*Linter) error
+
+// WithDir sets the directory of the main module, in which the go command is
+// run to determine the Go environment and the module sums. By default, the
+// working directory is used. Analyze uses the directory passed to it instead.
+func WithDir(dir string) Option {
+	return func(l *Linter) error {
+		l.dir = dir
+		return nil
+	}
+}
+
+// WithEnv adds the environment variables env in the form key=value to the
+// environment of the go commands run for the analysis, e.g. GOFLAGS or
+// GOPROXY. They override the variables of the environment of the process.
+func WithEnv(env ...string) Option {
+	return func(l *Linter) error {
+		for _, kv := range env {
+			if !strings.Contains(kv, "=") {
+				return fmt.Errorf("invalid environment variable %q, expected key=value", kv)
+			}
+		}
+		l.env = append(l.env, env...)
+		return nil
+	}
+}
+
+// WithContext sets the context of the analysis, if the Linter is used as
+// analyzer. The analysis of the remaining packages fails, once ctx is done.
+// Analyze and Check use the context passed to them instead, WithContext does
+// not affect them.
+func WithContext(ctx context.Context) Option {
+	return func(l *Linter) error {
+		if ctx == nil {
+			return errors.New("nil context")
+		}
+		l.ctx = ctx
+		return nil
+	}
+}
+
+// WithTimeout stops the analysis after the timeout. Used as analyzer, the
+// timeout starts with the analysis of the first package and its timer is
+// released by Close. Analyze and Check start the timeout with the loading of
+// the packages.
+func WithTimeout(timeout time.Duration) Option {
+	return func(l *Linter) error {
+		if timeout < 0 {
+			return fmt.Errorf("negative timeout %s", timeout)
+		}
+		l.timeout = timeout
+		return nil
+	}
+}
+
+// WithCacheDir sets the directory of the capabilities cache. By default, the
+// cache is located in the depcaps directory in the user cache directory.
+func WithCacheDir(cacheDir string) Option {
+	return func(l *Linter) error {
+		l.cacheDir = cacheDir
+		return nil
+	}
+}
+
+// WithoutCache disables the capabilities cache.
+func WithoutCache() Option {
+	return func(l *Linter) error {
+		l.noCache = true
+		return nil
+	}
+}
+
+// WithFailUnplaceable makes the analysis fail, if a finding can neither be
+// placed at a call site nor at an import.
+func WithFailUnplaceable(failUnplaceable bool) Option {
+	return func(l *Linter) error {
+		l.failUnplaceable = failUnplaceable
+		return nil
+	}
+}
+
+// WithPerModule makes Analyze and Check merge the violations of the same
+// capability of a dependency module into a single finding, which is placed at
+// the require directive of the module in go.mod. The findings of the packages
+// of the own module are kept in Affected of the merged finding. Used as
+// analyzer, the findings are reported per package.
+func WithPerModule(perModule bool) Option {
+	return func(l *Linter) error {
+		l.perModule = perModule
+		return nil
+	}
+}
+
+// WithCaseInsensitivePackages matches the package paths of the package rules
+// of the settings case-insensitively, e.g. for settings, whose keys have been
+// converted to lower case.
+func WithCaseInsensitivePackages() Option {
+	return func(l *Linter) error {
+		l.caseInsensitive = true
+		return nil
+	}
+}
+
+// WithConfigFile reads the settings from the config JSON file, replacing the
+// settings passed to New. Suggested fixes are only provided with a config
+// file.
+func WithConfigFile(configFile string) Option {
+	return func(l *Linter) error {
+		settings := &LinterSettings{}
+		err := settings.Set(configFile)
+		if err != nil {
+			return err
+		}
+		settings.CapslockBaselineFile = l.CapslockBaselineFile
+		l.LinterSettings = settings
+		return nil
+	}
+}
+
+// WithBaselineFile reads the capslock baseline file, which replaces the
+// baseline file of the settings.
+func WithBaselineFile(baselineFile string) Option {
+	return func(l *Linter) error {
+		err := l.readCapslockBaseline(baselineFile)
+		if err != nil {
+			return err
+		}
+		l.CapslockBaselineFile = baselineFile
+		return nil
+	}
+}
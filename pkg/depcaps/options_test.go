@@ -0,0 +1,122 @@
+package depcaps_test
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/breml/depcaps/pkg/depcaps"
+)
+
+func TestNewError(t *testing.T) {
+	tt := []struct {
+		name     string
+		settings *depcaps.LinterSettings
+		opts     []depcaps.Option
+
+		wantErr string
+	}{
+		{
+			name: "invalid capability",
+			settings: &depcaps.LinterSettings{
+				GlobalAllowedCapabilities: map[string]bool{"CAPABILITY_INVALID": true},
+			},
+		},
+		{
+			name: "baseline file not found",
+			opts: []depcaps.Option{depcaps.WithBaselineFile("testdata/notfound.json")},
+		},
+		{
+			name: "invalid baseline file",
+			opts: []depcaps.Option{depcaps.WithBaselineFile("testdata/invalid.json")},
+		},
+		{
+			name:    "baseline with short path",
+			opts:    []depcaps.Option{depcaps.WithBaselineFile("testdata/baseline_short_path.json")},
+			wantErr: `capabilityInfo[0]: capability info CAPABILITY_NETWORK with path "example.com/app.main": transitive capability with path of length 1`,
+		},
+		{
+			name:    "baseline with invalid function name",
+			opts:    []depcaps.Option{depcaps.WithBaselineFile("testdata/baseline_invalid_name.json")},
+			wantErr: `capabilityInfo[0]: invalid function name "GetTime": missing package path`,
+		},
+		{
+			name:    "baseline with invalid receiver",
+			opts:    []depcaps.Option{depcaps.WithBaselineFile("testdata/baseline_invalid_receiver.json")},
+			wantErr: `capabilityInfo[0]: invalid function name "(*example.com/app.Config.Load": missing closing ')'`,
+		},
+		{
+			name: "negative timeout",
+			opts: []depcaps.Option{depcaps.WithTimeout(-time.Second)},
+		},
+		{
+			name:    "invalid environment variable",
+			opts:    []depcaps.Option{depcaps.WithEnv("GOPROXY")},
+			wantErr: `invalid environment variable "GOPROXY", expected key=value`,
+		},
+		{
+			name: "nil context",
+			opts: []depcaps.Option{depcaps.WithContext(nil)}, //nolint:staticcheck // nil context is tested
+		},
+		{
+			name: "invalid config file",
+			opts: []depcaps.Option{depcaps.WithConfigFile("testdata/invalid_global_capability.json")},
+		},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := depcaps.New(tc.settings, tc.opts...)
+			if err == nil {
+				t.Fatalf("Expected error, got none")
+			}
+			if !strings.Contains(err.Error(), tc.wantErr) {
+				t.Fatalf("expected error containing %q, got: %v", tc.wantErr, err)
+			}
+		})
+	}
+}
+
+func TestIndependentLinters(t *testing.T) {
+	testCaseDir := chdirTestdata(t, "alltest")
+
+	settings := &depcaps.LinterSettings{
+		GlobalAllowedCapabilities: map[string]bool{},
+	}
+	cacheDir := t.TempDir()
+	strict := newLinter(t, settings, depcaps.WithCacheDir(cacheDir))
+
+	// Changing the settings after New does not affect the Linter.
+	settings.GlobalAllowedCapabilities["CAPABILITY_NETWORK"] = true
+	lenient := newLinter(t, settings, depcaps.WithCacheDir(cacheDir))
+
+	decisions := func(l *depcaps.Linter) map[string]depcaps.Decision {
+		findings, err := l.Analyze(context.Background(), testCaseDir, "./allow/...")
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		d := make(map[string]depcaps.Decision)
+		for _, f := range findings {
+			d[f.Capability.String()] = f.Decision
+		}
+		return d
+	}
+
+	if got := decisions(strict)["CAPABILITY_NETWORK"]; got != depcaps.Violation {
+		t.Fatalf("expected CAPABILITY_NETWORK to be a violation, got: %s", got)
+	}
+	if got := decisions(lenient)["CAPABILITY_NETWORK"]; got != depcaps.AllowedGlobal {
+		t.Fatalf("expected CAPABILITY_NETWORK to be allowed globally, got: %s", got)
+	}
+}
+
+func TestAnalyzerFlags(t *testing.T) {
+	a := newLinter(t, nil).AsAnalyzer(true)
+
+	// The findings are only merged per module by Analyze, so the analyzer
+	// must not offer it.
+	if a.Flags.Lookup("permodule") != nil {
+		t.Fatalf("expected analyzer without flag -permodule")
+	}
+}
@@ -0,0 +1,126 @@
+package depcaps
+
+import (
+	"go/ast"
+	"go/token"
+	"strings"
+
+	"github.com/google/capslock/proto"
+	"golang.org/x/tools/go/analysis"
+)
+
+// placement is the position, where a finding is reported, together with the
+// capability info providing the call path for the finding.
+type placement struct {
+	pos token.Pos
+	ci  *proto.CapabilityInfo
+
+	// unplaceable is true, if the finding could neither be placed at a call
+	// site nor at an import and is reported at the package clause or without
+	// a position instead.
+	unplaceable bool
+}
+
+// groupCapabilityInfos returns the capability infos of cil relevant for
+// packageName grouped by dependency package and capability.
+func groupCapabilityInfos(cil *proto.CapabilityInfoList, packageName, packagePrefix string) map[string]map[proto.Capability][]*proto.CapabilityInfo {
+	m := make(map[string]map[proto.Capability][]*proto.CapabilityInfo)
+	for _, ci := range cil.GetCapabilityInfo() {
+		// The capability infos of the summary are never malformed.
+		depPkg, ok, err := relevantCapabilityInfo(ci, packageName, packagePrefix)
+		if err != nil || !ok {
+			continue
+		}
+
+		if m[depPkg] == nil {
+			m[depPkg] = make(map[proto.Capability][]*proto.CapabilityInfo)
+		}
+		m[depPkg][ci.GetCapability()] = append(m[depPkg][ci.GetCapability()], ci)
+	}
+	return m
+}
+
+// place returns the position for the finding of the capability of depPkg
+// reached through cis. The finding is placed at the first call site into
+// depPkg. If no call site is known, e.g. for calls of package initializers,
+// the finding is placed at the import of depPkg and as last resort at the
+// package clause. If the package has no files, the finding is reported without
+// a position.
+//
+// Findings, which are only caused by test files, are not reported. In this
+// case, the second return value is false.
+func (d *Linter) place(pass *analysis.Pass, depPkg string, cis []*proto.CapabilityInfo) (placement, bool) {
+	withoutSite, testSite := false, false
+	for _, ci := range cis {
+		if len(ci.GetPath()) < 2 {
+			withoutSite = true
+			continue
+		}
+
+		pos := d.sitePos(pass, ci.GetPath()[1].GetSite())
+		if !pos.IsValid() || pass.Fset.File(pos) == nil {
+			withoutSite = true
+			continue
+		}
+		if isTestFile(pass.Fset.File(pos).Name()) {
+			testSite = true
+			continue
+		}
+
+		return placement{pos: pos, ci: ci}, true
+	}
+
+	if testSite && !withoutSite {
+		// All the calls into depPkg are located in test files.
+		return placement{}, false
+	}
+
+	var ci *proto.CapabilityInfo
+	if len(cis) > 0 {
+		ci = cis[0]
+	}
+
+	var testImport bool
+	for _, file := range pass.Files {
+		for _, spec := range file.Imports {
+			if depPkg != strings.Trim(spec.Path.Value, `"`) {
+				continue
+			}
+			if isTestFile(pass.Fset.File(file.Pos()).Name()) {
+				testImport = true
+				continue
+			}
+			return placement{pos: spec.Pos(), ci: ci}, true
+		}
+	}
+
+	if testImport {
+		// depPkg is only imported by test files.
+		return placement{}, false
+	}
+
+	return placement{pos: packageClause(pass), ci: ci, unplaceable: true}, true
+}
+
+// packageClause returns the position of the package clause of the first
+// non-test file of the package.
+func packageClause(pass *analysis.Pass) token.Pos {
+	var first *ast.File
+	for _, file := range pass.Files {
+		if first == nil {
+			first = file
+		}
+		if !isTestFile(pass.Fset.File(file.Pos()).Name()) {
+			return file.Package
+		}
+	}
+
+	if first == nil {
+		return token.NoPos
+	}
+	return first.Package
+}
+
+func isTestFile(filename string) bool {
+	return strings.HasSuffix(filename, "_test.go")
+}
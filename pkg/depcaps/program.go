@@ -0,0 +1,621 @@
+package depcaps
+
+import (
+	"go/token"
+	"go/types"
+	"sort"
+	"strings"
+
+	"github.com/google/capslock/proto"
+	"golang.org/x/tools/go/analysis"
+	"golang.org/x/tools/go/ssa"
+	pb "google.golang.org/protobuf/proto"
+)
+
+// programSummary is the part of the fact of a package, which is needed to
+// resolve the dynamic calls against the types of the whole program. Only the
+// facts of the direct imports are available to a package, if the packages
+// are analyzed in separate processes, e.g. by go vet. Therefore the summary
+// holds the parts of the package and of all its dependencies by package path.
+type programSummary struct {
+	Packages map[string]*programPackage
+}
+
+// programPackage is the part of the program summary of a single package.
+type programPackage struct {
+	// Reaches holds the dynamic calls reached by the functions of the package
+	// by function name.
+	Reaches map[string]reach
+	// Sites holds the dynamic calls of the functions of the package by the
+	// node of their receiver in the type flow graph.
+	Sites map[string]dynamicCall
+	// Types holds the types converted to an interface by the package, which
+	// have methods with capabilities or reaching dynamic calls.
+	Types map[string]*programType
+	// Flows holds the type flow graph of the package.
+	Flows map[string]*typeFlow
+}
+
+// dynamicCall is a dynamic call of the method with the key Method of the
+// interface with the method keys Interface, which are joined by ";".
+type dynamicCall struct {
+	Interface string
+	Method    string
+}
+
+// reach holds the dynamic calls reached by a function, either directly at the
+// call sites Sites or by calling the functions Vias.
+type reach struct {
+	Sites []string
+	Vias  []string
+}
+
+// programType is a concrete type, which is converted to an interface.
+type programType struct {
+	// Keys holds the sorted method keys of the method set of the type.
+	Keys []string
+	// Methods holds the methods with capabilities or reaching dynamic calls
+	// by method key.
+	Methods map[string]programMethod
+}
+
+// programMethod is a method of a programType.
+type programMethod struct {
+	Name         string
+	Package      string
+	Capabilities []proto.Capability
+}
+
+// methodKey identifies a method by its name and signature, such that the
+// methods of concrete types can be matched with the methods of interfaces
+// without type information. The names of unexported methods are qualified
+// by their package.
+func methodKey(m *types.Func) string {
+	name := m.Name()
+	if !m.Exported() && m.Pkg() != nil {
+		name = m.Pkg().Path() + "." + name
+	}
+	sig := m.Type().(*types.Signature)
+	signature := types.NewSignatureType(nil, nil, nil, unnamed(sig.Params()), unnamed(sig.Results()), sig.Variadic())
+	return name + strings.TrimPrefix(types.TypeString(signature, nil), "func")
+}
+
+// unnamed returns tuple without the names of its variables.
+func unnamed(tuple *types.Tuple) *types.Tuple {
+	vars := make([]*types.Var, 0, tuple.Len())
+	for i := 0; i < tuple.Len(); i++ {
+		vars = append(vars, types.NewParam(token.NoPos, nil, "", tuple.At(i).Type()))
+	}
+	return types.NewTuple(vars...)
+}
+
+// newDynamicCall returns the dynamic call of method of iface.
+func newDynamicCall(iface *types.Interface, method *types.Func) dynamicCall {
+	keys := make([]string, 0, iface.NumMethods())
+	for i := 0; i < iface.NumMethods(); i++ {
+		keys = append(keys, methodKey(iface.Method(i)))
+	}
+	sort.Strings(keys)
+	return dynamicCall{Interface: strings.Join(keys, ";"), Method: methodKey(method)}
+}
+
+// implementedBy reports, if the dynamic call might be dispatched to t.
+func (c dynamicCall) implementedBy(t *programType) bool {
+	if _, ok := t.Methods[c.Method]; !ok {
+		return false
+	}
+	for _, key := range strings.Split(c.Interface, ";") {
+		if i := sort.SearchStrings(t.Keys, key); i == len(t.Keys) || t.Keys[i] != key {
+			return false
+		}
+	}
+	return true
+}
+
+// convertedTypes returns the concrete types, which are converted to an
+// interface by fns (rapid type analysis). The type arguments of instances of
+// generic functions and types are included, as they might be converted in
+// the generic code of other packages, which is not available.
+func convertedTypes(pass *analysis.Pass, fns []*ssa.Function) []types.Type {
+	seen := make(map[string]bool)
+	var converted []types.Type
+	add := func(t types.Type) {
+		name := types.TypeString(t, nil)
+		if types.IsInterface(t) || seen[name] {
+			return
+		}
+		seen[name] = true
+		converted = append(converted, t)
+	}
+
+	for _, fn := range fns {
+		for _, b := range fn.Blocks {
+			for _, instr := range b.Instrs {
+				if mi, ok := instr.(*ssa.MakeInterface); ok {
+					add(mi.X.Type())
+				}
+			}
+		}
+	}
+	for _, inst := range pass.TypesInfo.Instances {
+		for i := 0; i < inst.TypeArgs.Len(); i++ {
+			t := inst.TypeArgs.At(i)
+			add(t)
+			add(types.NewPointer(t))
+		}
+	}
+
+	sort.Slice(converted, func(i, j int) bool {
+		return types.TypeString(converted[i], nil) < types.TypeString(converted[j], nil)
+	})
+	return converted
+}
+
+// collectTypes collects the parts of the program summaries of the
+// dependencies and their converted types from their facts and the types
+// converted by the package itself.
+func (s *summary) collectTypes(ssapkg *ssa.Package) {
+	s.parts = make(map[string]*programPackage)
+	for _, fact := range s.facts {
+		if fact.program == nil {
+			continue
+		}
+		for pkgPath, part := range fact.program.Packages {
+			s.parts[pkgPath] = part
+		}
+	}
+
+	s.types = make(map[string]*programType)
+	for _, part := range s.parts {
+		for name, t := range part.Types {
+			s.types[name] = mergeProgramTypes(s.types[name], t)
+		}
+	}
+
+	s.ownConverted = convertedTypes(s.pass, packageFunctions(ssapkg))
+}
+
+// mergeProgramTypes returns the union of the methods of a and b. The facts
+// of different packages might know different capabilities of the same type.
+func mergeProgramTypes(a, b *programType) *programType {
+	if a == nil {
+		return b
+	}
+	if b == nil {
+		return a
+	}
+
+	merged := &programType{Keys: a.Keys, Methods: make(map[string]programMethod, len(a.Methods))}
+	for key, m := range a.Methods {
+		merged.Methods[key] = m
+	}
+	for key, m := range b.Methods {
+		if existing, ok := merged.Methods[key]; ok {
+			m.Capabilities = unionCapabilities(existing.Capabilities, m.Capabilities)
+		}
+		merged.Methods[key] = m
+	}
+	return merged
+}
+
+func unionCapabilities(a, b []proto.Capability) []proto.Capability {
+	set := make(map[proto.Capability]*proto.CapabilityInfo, len(a)+len(b))
+	for _, c := range a {
+		set[c] = nil
+	}
+	for _, c := range b {
+		set[c] = nil
+	}
+	return sortedCapabilities(set)
+}
+
+// programType returns the programType of t or nil, if no method of t has
+// capabilities or reaches dynamic calls according to reaches and the facts.
+func (s *summary) programType(t types.Type, reaches map[string]reach) *programType {
+	if named, ok := derefNamed(t); !ok || named.TypeArgs().Len() > 0 {
+		return nil
+	}
+
+	mset := s.prog.MethodSets.MethodSet(t)
+	pt := &programType{
+		Keys:    make([]string, 0, mset.Len()),
+		Methods: make(map[string]programMethod),
+	}
+	for i := 0; i < mset.Len(); i++ {
+		m := mset.At(i).Obj().(*types.Func)
+		key := methodKey(m)
+		pt.Keys = append(pt.Keys, key)
+
+		// Methods promoted from embedded interfaces have no function, calling
+		// them is another dynamic call.
+		fn := s.prog.FuncValue(m)
+		if fn == nil {
+			continue
+		}
+		name := fn.String()
+		caps := s.lookup(m.Pkg().Path(), name)
+		if _, ok := reaches[name]; !ok && len(caps) == 0 && !s.factReaches(m.Pkg().Path(), name) {
+			continue
+		}
+		pt.Methods[key] = programMethod{
+			Name:         name,
+			Package:      m.Pkg().Path(),
+			Capabilities: sortedCapabilities(caps),
+		}
+	}
+	sort.Strings(pt.Keys)
+
+	if len(pt.Methods) == 0 {
+		return nil
+	}
+	return pt
+}
+
+func derefNamed(t types.Type) (*types.Named, bool) {
+	if ptr, ok := t.(*types.Pointer); ok {
+		t = ptr.Elem()
+	}
+	named, ok := t.(*types.Named)
+	return named, ok
+}
+
+// reaches returns the dynamic calls reached by the functions of the package.
+// A function reaches dynamic calls at its own call sites or by calling other
+// functions reaching dynamic calls.
+func (s *summary) reaches() map[*ssa.Function]reach {
+	reaching := make(map[*ssa.Function]bool, len(s.nodes))
+	for _, fn := range s.nodes {
+		if s.isLocal(fn) {
+			reaching[fn] = len(s.invokes[fn]) > 0
+		} else if pkg := functionPackage(fn); pkg != nil {
+			reaching[fn] = s.factReaches(pkg.Path(), s.names[fn])
+		}
+	}
+	for changed := true; changed; {
+		changed = false
+		for _, fn := range s.functions {
+			if reaching[fn] {
+				continue
+			}
+			for _, e := range s.edges[fn] {
+				if reaching[e.callee] {
+					reaching[fn] = true
+					changed = true
+					break
+				}
+			}
+		}
+	}
+
+	reaches := make(map[*ssa.Function]reach)
+	for _, fn := range s.functions {
+		if !reaching[fn] {
+			continue
+		}
+		var r reach
+		seen := make(map[string]bool)
+		for _, site := range s.invokes[fn] {
+			if !seen[site.node] {
+				seen[site.node] = true
+				r.Sites = append(r.Sites, site.node)
+			}
+		}
+		for _, e := range s.edges[fn] {
+			if name := s.names[e.callee]; e.callee != fn && reaching[e.callee] && !seen[name] {
+				seen[name] = true
+				r.Vias = append(r.Vias, name)
+			}
+		}
+		sort.Strings(r.Sites)
+		sort.Strings(r.Vias)
+		reaches[fn] = r
+	}
+	return reaches
+}
+
+// factReaches reports, if the function with the given name of the package
+// with the given path reaches dynamic calls according to the facts.
+func (s *summary) factReaches(pkgPath, name string) bool {
+	part, ok := s.parts[pkgPath]
+	if !ok {
+		return false
+	}
+	_, ok = part.Reaches[name]
+	return ok
+}
+
+// programSummary returns the program summary of the package for its fact,
+// which extends the parts of the dependencies by the part of the package.
+func (s *summary) programSummary() *programSummary {
+	own := &programPackage{
+		Reaches: make(map[string]reach),
+		Sites:   make(map[string]dynamicCall),
+		Types:   make(map[string]*programType),
+		Flows:   s.flows,
+	}
+	for fn, r := range s.reaches() {
+		own.Reaches[s.names[fn]] = r
+	}
+	for _, fn := range s.functions {
+		for _, site := range s.invokes[fn] {
+			own.Sites[site.node] = site.call
+		}
+	}
+	for _, t := range s.ownConverted {
+		if pt := s.programType(t, own.Reaches); pt != nil {
+			own.Types[types.TypeString(t, nil)] = pt
+		}
+	}
+
+	ps := &programSummary{Packages: make(map[string]*programPackage, len(s.parts)+1)}
+	for pkgPath, part := range s.parts {
+		ps.Packages[pkgPath] = part
+	}
+	ps.Packages[s.pass.Pkg.Path()] = own
+	return ps
+}
+
+// resolveProgram resolves the dynamic calls reached by the functions of the
+// dependencies against the types of the whole program, like capslock does
+// with the call graph of the whole program. The dependencies only resolved
+// their dynamic calls against the types known to them. The capabilities
+// gained through the additional callees are kept in s.program, which extends
+// the capabilities of the facts.
+//
+// A dynamic call is resolved to the methods of the types, which flow to the
+// receiver of the call according to the type flow graph of the program, like
+// capslock refines its call graph with the variable type analysis. The types
+// of the package itself are not considered, as the package does not export a
+// fact and its findings only depend on the capabilities reached through the
+// dependencies.
+func (s *summary) resolveProgram() {
+	programTypes := make(map[string]*programType, len(s.types))
+	for name, t := range s.types {
+		programTypes[name] = t
+	}
+	for _, t := range s.ownConverted {
+		if named, ok := derefNamed(t); !ok || named.Obj().Pkg() == s.pass.Pkg {
+			continue
+		}
+		name := types.TypeString(t, nil)
+		programTypes[name] = mergeProgramTypes(programTypes[name], s.programType(t, nil))
+		if programTypes[name] == nil {
+			delete(programTypes, name)
+		}
+	}
+
+	// Index the functions reaching dynamic calls, their packages and the
+	// capabilities of the methods.
+	packages := make(map[string]string)
+	methodCapabilities := make(map[string][]proto.Capability)
+	for _, t := range programTypes {
+		for _, m := range t.Methods {
+			packages[m.Name] = m.Package
+			methodCapabilities[m.Name] = unionCapabilities(methodCapabilities[m.Name], m.Capabilities)
+		}
+	}
+	owners := make(map[string]*packageCapabilities)
+	for _, fact := range s.facts {
+		for name := range fact.index() {
+			owners[name] = fact
+		}
+	}
+	reaches := make(map[string]reach)
+	sites := make(map[string]dynamicCall)
+	for pkgPath, part := range s.parts {
+		for name, r := range part.Reaches {
+			reaches[name] = r
+			if _, ok := packages[name]; !ok {
+				packages[name] = pkgPath
+			}
+		}
+		for node, call := range part.Sites {
+			sites[node] = call
+		}
+	}
+
+	has := func(name string, c proto.Capability) bool {
+		if _, ok := s.program[name][c]; ok {
+			return true
+		}
+		if fact, ok := owners[name]; ok {
+			if _, ok := fact.function(name)[c]; ok {
+				return true
+			}
+		}
+		for _, mc := range methodCapabilities[name] {
+			if mc == c {
+				return true
+			}
+		}
+		return false
+	}
+
+	// Several types might share a method, e.g. through embedding.
+	targets := make(map[string][]string, len(sites))
+	for node, typeNames := range s.siteTypes {
+		call, ok := sites[node]
+		if !ok {
+			continue
+		}
+		seen := make(map[string]bool)
+		for _, name := range typeNames {
+			t, ok := programTypes[name]
+			if !ok || !call.implementedBy(t) || seen[t.Methods[call.Method].Name] {
+				continue
+			}
+			seen[t.Methods[call.Method].Name] = true
+			targets[node] = append(targets[node], t.Methods[call.Method].Name)
+		}
+	}
+
+	names := make([]string, 0, len(reaches))
+	for name := range reaches {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	// Compute the capabilities gained through the dynamic calls until a fixed
+	// point is reached. Like the capabilities of the functions, they are
+	// propagated one call per round, such that the callee, which provides a
+	// capability with the least number of calls, is kept.
+	type update struct {
+		name       string
+		capability proto.Capability
+		callee     string
+	}
+	// Methods with a capability of their own are preferred over methods
+	// gaining it by calling other functions.
+	direct := func(name string, c proto.Capability) bool {
+		fact, ok := owners[name]
+		return ok && len(fact.function(name)[c].GetPath()) == 1
+	}
+	gained := make(map[string]map[proto.Capability]string, len(names))
+	for _, name := range names {
+		gained[name] = make(map[proto.Capability]string)
+		for _, site := range reaches[name].Sites {
+			for _, target := range targets[site] {
+				for _, c := range methodCapabilities[target] {
+					if existing, ok := gained[name][c]; !ok || direct(target, c) && !direct(existing, c) {
+						gained[name][c] = target
+					}
+				}
+			}
+		}
+	}
+	for {
+		var updates []update
+		for _, name := range names {
+			callees := append([]string(nil), reaches[name].Vias...)
+			for _, site := range reaches[name].Sites {
+				callees = append(callees, targets[site]...)
+			}
+			for _, callee := range callees {
+				for c := range gained[callee] {
+					if _, ok := gained[name][c]; !ok {
+						updates = append(updates, update{name: name, capability: c, callee: callee})
+					}
+				}
+			}
+		}
+
+		if len(updates) == 0 {
+			break
+		}
+
+		for _, u := range updates {
+			if callee, ok := gained[u.name][u.capability]; !ok || u.callee < callee {
+				gained[u.name][u.capability] = u.callee
+			}
+		}
+	}
+
+	pkgNames := make(map[string]string)
+	var visitImports func(p *types.Package)
+	visitImports = func(p *types.Package) {
+		for _, imp := range p.Imports() {
+			if _, ok := pkgNames[imp.Path()]; !ok {
+				pkgNames[imp.Path()] = imp.Name()
+				visitImports(imp)
+			}
+		}
+	}
+	visitImports(s.pass.Pkg)
+
+	for _, name := range names {
+		caps := make(map[proto.Capability]*proto.CapabilityInfo, len(gained[name]))
+		for c := range gained[name] {
+			caps[c] = nil
+		}
+		for _, c := range sortedCapabilities(caps) {
+			if has(name, c) {
+				continue
+			}
+			callee := gained[name][c]
+			if s.program[name] == nil {
+				s.program[name] = make(map[proto.Capability]*proto.CapabilityInfo)
+			}
+			s.program[name][c] = programCapabilityInfo(c, name, packages[name], callee, packages[callee], pkgNames)
+		}
+	}
+}
+
+// flowTypes returns the types flowing to the nodes of the type flow graph of
+// the package and its dependencies by node. Only the nodes of receivers of
+// dynamic calls are returned.
+func (s *summary) flowTypes() map[string][]string {
+	var typeNames []string
+	typeIDs := make(map[string]int)
+	ids := make(map[string]int)
+	var nodes []string
+	var succs, initial [][]int
+	id := func(node string) int {
+		i, ok := ids[node]
+		if !ok {
+			i = len(nodes)
+			ids[node] = i
+			nodes = append(nodes, node)
+			succs = append(succs, nil)
+			initial = append(initial, nil)
+		}
+		return i
+	}
+	addFlows := func(flows map[string]*typeFlow) {
+		for node, tf := range flows {
+			to := id(node)
+			for _, from := range tf.From {
+				i := id(from)
+				succs[i] = append(succs[i], to)
+			}
+			for _, name := range tf.Types {
+				t, ok := typeIDs[name]
+				if !ok {
+					t = len(typeNames)
+					typeIDs[name] = t
+					typeNames = append(typeNames, name)
+				}
+				initial[to] = append(initial[to], t)
+			}
+		}
+	}
+	for _, part := range s.parts {
+		addFlows(part.Flows)
+	}
+	addFlows(s.flows)
+	// The values passed to panic are returned by recover.
+	addFlows(map[string]*typeFlow{"recover": {From: []string{"panic"}}})
+
+	flowTypes := make(map[string][]string)
+	for i, set := range reachingSets(succs, initial) {
+		if len(set) == 0 || !strings.HasPrefix(nodes[i], "site ") {
+			continue
+		}
+		for _, t := range set {
+			flowTypes[nodes[i]] = append(flowTypes[nodes[i]], typeNames[t])
+		}
+		// The types are numbered in random order, sort them for stable
+		// results.
+		sort.Strings(flowTypes[nodes[i]])
+	}
+	return flowTypes
+}
+
+// programCapabilityInfo returns the capability info for the function caller,
+// which gains capability by calling callee.
+func programCapabilityInfo(capability proto.Capability, caller, callerPkg, callee, calleePkg string, pkgNames map[string]string) *proto.CapabilityInfo {
+	capabilityType := proto.CapabilityType_CAPABILITY_TYPE_DIRECT
+	if calleePkg != callerPkg && !isStdLib(calleePkg) {
+		capabilityType = proto.CapabilityType_CAPABILITY_TYPE_TRANSITIVE
+	}
+
+	return &proto.CapabilityInfo{
+		PackageName: pb.String(pkgNames[callerPkg]),
+		Capability:  capability.Enum(),
+		Path: []*proto.Function{
+			{Name: pb.String(caller), Package: pb.String(callerPkg)},
+			{Name: pb.String(callee), Package: pb.String(calleePkg)},
+		},
+		PackageDir:     pb.String(callerPkg),
+		CapabilityType: capabilityType.Enum(),
+	}
+}
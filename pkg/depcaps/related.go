@@ -0,0 +1,99 @@
+package depcaps
+
+import (
+	"fmt"
+	"go/token"
+	"os"
+
+	"github.com/google/capslock/proto"
+	"golang.org/x/tools/go/analysis"
+)
+
+// relatedInformation returns the call path of ci as related information.
+// Every call with a known call site results in one entry, starting with the
+// call in the analyzed package. Call sites in files, which are not part of
+// the file set of pass, are skipped, if the file can not be read.
+func (d *Linter) relatedInformation(pass *analysis.Pass, ci *proto.CapabilityInfo) []analysis.RelatedInformation {
+	var related []analysis.RelatedInformation
+	path := ci.GetPath()
+	for i := 1; i < len(path); i++ {
+		pos := d.sitePos(pass, path[i].GetSite())
+		if !pos.IsValid() {
+			continue
+		}
+
+		related = append(related, analysis.RelatedInformation{
+			Pos:     pos,
+			Message: fmt.Sprintf("%s calls %s", path[i-1].GetName(), path[i].GetName()),
+		})
+	}
+
+	return related
+}
+
+// sitePos returns the position of site in the file set of pass. Sites in the
+// files of the analyzed package use these files, the files of dependencies
+// are added to the file set.
+func (d *Linter) sitePos(pass *analysis.Pass, site *proto.Function_Site) token.Pos {
+	if site.GetFilename() == "" || site.GetLine() < 1 || site.GetColumn() < 1 {
+		return token.NoPos
+	}
+
+	var file *token.File
+	for _, f := range pass.Files {
+		if tf := pass.Fset.File(f.Pos()); tf != nil && tf.Name() == site.GetFilename() {
+			file = tf
+			break
+		}
+	}
+	if file == nil {
+		file = d.tokenFile(pass.Fset, site.GetFilename(), func() ([]byte, error) {
+			return os.ReadFile(site.GetFilename())
+		})
+	}
+	if file == nil {
+		return token.NoPos
+	}
+
+	if int(site.GetLine()) > file.LineCount() {
+		return token.NoPos
+	}
+	offset := file.Offset(file.LineStart(int(site.GetLine()))) + int(site.GetColumn()) - 1
+	if offset > file.Size() {
+		return token.NoPos
+	}
+
+	return file.Pos(offset)
+}
+
+// fileKey identifies a file added to a file set.
+type fileKey struct {
+	fset *token.FileSet
+	name string
+}
+
+// tokenFile returns the file with the given name, which is added to fset
+// with the content returned by read on first use. The file is added once
+// per file set, even if the analysis of several packages asks for it
+// concurrently. If read fails, nil is returned.
+func (d *Linter) tokenFile(fset *token.FileSet, name string, read func() ([]byte, error)) *token.File {
+	d.filesMu.Lock()
+	defer d.filesMu.Unlock()
+
+	key := fileKey{fset: fset, name: name}
+	if tf, ok := d.files[key]; ok {
+		return tf
+	}
+
+	var tf *token.File
+	content, err := read()
+	if err == nil {
+		tf = fset.AddFile(name, -1, len(content))
+		tf.SetLinesForContent(content)
+	}
+	if d.files == nil {
+		d.files = make(map[fileKey]*token.File)
+	}
+	d.files[key] = tf
+	return tf
+}
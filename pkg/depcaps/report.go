@@ -0,0 +1,215 @@
+package depcaps
+
+import (
+	"fmt"
+	"go/token"
+	"io"
+
+	"github.com/google/capslock/proto"
+	"golang.org/x/tools/go/analysis"
+)
+
+// Decision tells, if a capability is allowed and by what.
+type Decision int
+
+const (
+	// Violation is a capability, which is not allowed.
+	Violation Decision = iota
+	// AllowedGlobal is a capability allowed by GlobalAllowedCapabilities.
+	AllowedGlobal
+	// AllowedPackage is a capability allowed by PackageAllowedCapabilities.
+	AllowedPackage
+	// AllowedBaseline is a capability covered by the capslock baseline.
+	AllowedBaseline
+	// AllowedDirective is a capability allowed by a //depcaps:allow
+	// directive on the import of the dependency.
+	AllowedDirective
+)
+
+var decisionNames = map[Decision]string{
+	Violation:        "violation",
+	AllowedGlobal:    "global",
+	AllowedPackage:   "package",
+	AllowedBaseline:  "baseline",
+	AllowedDirective: "directive",
+}
+
+func (d Decision) String() string {
+	return decisionNames[d]
+}
+
+func (d Decision) MarshalText() ([]byte, error) {
+	name, ok := decisionNames[d]
+	if !ok {
+		return nil, fmt.Errorf("invalid decision %d", int(d))
+	}
+	return []byte(name), nil
+}
+
+func (d *Decision) UnmarshalText(text []byte) error {
+	for decision, name := range decisionNames {
+		if name == string(text) {
+			*d = decision
+			return nil
+		}
+	}
+	return fmt.Errorf("invalid decision %q", text)
+}
+
+// Finding is a capability of a dependency package, which is reached from a
+// package of the own module.
+type Finding struct {
+	// Package is the package of the own module, which reaches the capability.
+	Package string
+	// Dependency is the package of the dependency, which has the capability.
+	Dependency string
+	// Module and Version are the module of Dependency. They are only known,
+	// if the findings are the result of Analyze.
+	Module     string
+	Version    string
+	Capability proto.Capability
+	Decision   Decision
+	// Severity is the severity of a violation, it is SeverityNone for
+	// allowed capabilities.
+	Severity Severity
+
+	// Position is the position, where the finding is reported, that is the
+	// call site, the import or the package clause.
+	Position token.Position
+	// Path is the call path, through which the capability is reached,
+	// starting with the function in Package.
+	Path []CallSite
+	// Paths holds all the known call paths from Package to the capability,
+	// not only the one of the reported call site.
+	Paths [][]CallSite
+
+	// Unapproved is true for a required module, which is not listed in the
+	// config, while RequireExplicitApproval is set. The finding has neither
+	// package, dependency nor capability, it is reported at the require
+	// directive of Module in go.mod.
+	Unapproved bool
+	// Affected holds the violations of the packages of the own module, which
+	// are merged into this finding of the capability of Module, see
+	// WithPerModule. The merged finding has neither package, dependency nor
+	// call path, it is reported at the require directive of Module in go.mod.
+	Affected []Finding
+	// Problem describes a problem of the analysis of Package, which is not a
+	// capability, e.g. a malformed or unused //depcaps:allow directive. The
+	// finding has neither dependency nor capability, it is a violation with
+	// severity error, like the diagnostic reported for the problem.
+	Problem string
+}
+
+// CallSite is a function on the call path of a finding together with the
+// position, where it is called.
+type CallSite struct {
+	Function string
+	// Package is the package of Function. Module is the module of Package,
+	// it is only known, if the findings are the result of Analyze.
+	Package string
+	Module  string
+	// Position is the position of the call of Function. It is the zero value,
+	// if the call site is not known, e.g. for the first function of the path.
+	Position token.Position
+}
+
+// reportProblem reports the problem of the analysis of the package analyzed
+// by pass as diagnostic of category at pos and returns the finding of the
+// problem.
+func reportProblem(pass *analysis.Pass, pos token.Pos, category, message string) Finding {
+	pass.Report(analysis.Diagnostic{
+		Pos:      pos,
+		Category: category,
+		Message:  message,
+	})
+	return Finding{
+		Package:  pass.Pkg.Path(),
+		Decision: Violation,
+		Severity: SeverityError,
+		Position: pass.Fset.Position(pos),
+		Problem:  message,
+	}
+}
+
+func newFinding(pass *analysis.Pass, pkg string, c proto.Capability, decision Decision, severity Severity, p placement, cis []*proto.CapabilityInfo) Finding {
+	f := Finding{
+		Package:    pass.Pkg.Path(),
+		Dependency: pkg,
+		Capability: c,
+		Decision:   decision,
+		Severity:   severity,
+		Position:   pass.Fset.Position(p.pos),
+		Path:       callPath(p.ci),
+	}
+
+	for _, ci := range cis {
+		if len(ci.GetPath()) < 2 || isTestFile(ci.GetPath()[1].GetSite().GetFilename()) {
+			continue
+		}
+		f.Paths = append(f.Paths, callPath(ci))
+	}
+
+	return f
+}
+
+// callPath returns the path of ci as call sites.
+func callPath(ci *proto.CapabilityInfo) []CallSite {
+	var path []CallSite
+	for _, fn := range ci.GetPath() {
+		// The package of a malformed function name is unknown.
+		pkg, _ := packagePath(fn)
+		cs := CallSite{
+			Function: fn.GetName(),
+			Package:  pkg,
+		}
+		if site := fn.GetSite(); site.GetFilename() != "" {
+			cs.Position = token.Position{
+				Filename: site.GetFilename(),
+				Line:     int(site.GetLine()),
+				Column:   int(site.GetColumn()),
+			}
+		}
+		path = append(path, cs)
+	}
+	return path
+}
+
+// Violations returns the findings with capabilities, which are not allowed.
+func Violations(findings []Finding) []Finding {
+	var violations []Finding
+	for _, f := range findings {
+		if f.Decision == Violation {
+			violations = append(violations, f)
+		}
+	}
+	return violations
+}
+
+// WriteText writes the violations of the findings to w, one line per
+// violation in the form position: severity: message. A violation merged per
+// module is followed by indented lines with the positions of up to three of
+// the affected packages.
+func WriteText(w io.Writer, findings []Finding) error {
+	for _, f := range Violations(findings) {
+		_, err := fmt.Fprintf(w, "%s: %s: %s\n", f.Position, f.Severity, f.message())
+		if err != nil {
+			return err
+		}
+
+		pkgs := f.affectedPackages()
+		for i, a := range pkgs {
+			if i == maxAffectedPackages {
+				_, err = fmt.Fprintf(w, "\t... %d more\n", len(pkgs)-maxAffectedPackages)
+				if err != nil {
+					return err
+				}
+				break
+			}
+			_, err = fmt.Fprintf(w, "\t%s: reached by package %s\n", a.Position, a.Package)
+			if err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
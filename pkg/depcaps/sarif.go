@@ -0,0 +1,301 @@
+package depcaps
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"go/token"
+	"io"
+	"net/url"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+const (
+	sarifVersion = "2.1.0"
+	sarifSchema  = "https://json.schemastore.org/sarif-2.1.0.json"
+
+	// sarifSrcRoot is the base of the URIs of the files in the base directory.
+	sarifSrcRoot = "%SRCROOT%"
+
+	// sarifFingerprint is the key of the fingerprint of a result. The version
+	// needs to change, if the way the fingerprint is calculated is changed.
+	sarifFingerprint = "depcapsFinding/v1"
+)
+
+type sarifLog struct {
+	Version string     `json:"version"`
+	Schema  string     `json:"$schema"`
+	Runs    []sarifRun `json:"runs"`
+}
+
+type sarifRun struct {
+	Tool               sarifTool                   `json:"tool"`
+	OriginalURIBaseIDs map[string]sarifArtifactLoc `json:"originalUriBaseIds,omitempty"`
+	Results            []sarifResult               `json:"results"`
+}
+
+type sarifTool struct {
+	Driver sarifDriver `json:"driver"`
+}
+
+type sarifDriver struct {
+	Name           string      `json:"name"`
+	InformationURI string      `json:"informationUri"`
+	Rules          []sarifRule `json:"rules"`
+}
+
+type sarifRule struct {
+	ID               string       `json:"id"`
+	Name             string       `json:"name"`
+	ShortDescription sarifMessage `json:"shortDescription"`
+	HelpURI          string       `json:"helpUri"`
+}
+
+type sarifResult struct {
+	RuleID              string            `json:"ruleId"`
+	RuleIndex           int               `json:"ruleIndex"`
+	Level               string            `json:"level"`
+	Message             sarifMessage      `json:"message"`
+	Locations           []sarifLocation   `json:"locations"`
+	RelatedLocations    []sarifLocation   `json:"relatedLocations,omitempty"`
+	CodeFlows           []sarifCodeFlow   `json:"codeFlows,omitempty"`
+	PartialFingerprints map[string]string `json:"partialFingerprints"`
+}
+
+type sarifMessage struct {
+	Text string `json:"text"`
+}
+
+type sarifLocation struct {
+	PhysicalLocation sarifPhysicalLocation `json:"physicalLocation"`
+	Message          *sarifMessage         `json:"message,omitempty"`
+}
+
+type sarifPhysicalLocation struct {
+	ArtifactLocation sarifArtifactLoc `json:"artifactLocation"`
+	Region           *sarifRegion     `json:"region,omitempty"`
+}
+
+type sarifArtifactLoc struct {
+	URI       string `json:"uri"`
+	URIBaseID string `json:"uriBaseId,omitempty"`
+}
+
+type sarifRegion struct {
+	StartLine   int `json:"startLine"`
+	StartColumn int `json:"startColumn,omitempty"`
+}
+
+type sarifCodeFlow struct {
+	ThreadFlows []sarifThreadFlow `json:"threadFlows"`
+}
+
+type sarifThreadFlow struct {
+	Locations []sarifThreadFlowLocation `json:"locations"`
+}
+
+type sarifThreadFlowLocation struct {
+	Location sarifLocation `json:"location"`
+}
+
+// WriteSARIF writes the violations of the findings as SARIF 2.1.0 log to w.
+// Every capability is a rule with the ID depcaps/<capability>, the unapproved
+// modules share the rule depcaps/UNAPPROVED_MODULE and the problems of the
+// analysis the rule depcaps/ANALYSIS_PROBLEM. Files in baseDir are
+// referenced relative to baseDir, all the other files by their absolute path.
+func WriteSARIF(w io.Writer, baseDir string, findings []Finding) error {
+	baseDir, err := filepath.Abs(baseDir)
+	if err != nil {
+		return err
+	}
+
+	findings = Violations(findings)
+
+	run := sarifRun{
+		Tool: sarifTool{
+			Driver: sarifDriver{
+				Name:           "depcaps",
+				InformationURI: "https://github.com/breml/depcaps",
+				Rules:          []sarifRule{},
+			},
+		},
+		OriginalURIBaseIDs: map[string]sarifArtifactLoc{
+			sarifSrcRoot: {URI: fileURI(baseDir) + "/"},
+		},
+		Results: []sarifResult{},
+	}
+
+	ruleIndex := make(map[string]int)
+	for _, c := range findingRules(findings) {
+		description := fmt.Sprintf("Dependency package has capability %s", c)
+		switch c {
+		case unapprovedRule:
+			description = "Dependency module is not approved by the config"
+		case problemRule:
+			description = "Problem of the analysis, e.g. a malformed or unused //depcaps:allow directive"
+		}
+		ruleIndex[c] = len(run.Tool.Driver.Rules)
+		run.Tool.Driver.Rules = append(run.Tool.Driver.Rules, sarifRule{
+			ID:               sarifRuleID(c),
+			Name:             c,
+			ShortDescription: sarifMessage{Text: description},
+			HelpURI:          "https://github.com/breml/depcaps#config-json-file",
+		})
+	}
+
+	for _, f := range findings {
+		result := sarifResult{
+			RuleID:    sarifRuleID(ruleName(f)),
+			RuleIndex: ruleIndex[ruleName(f)],
+			Level:     sarifLevel(f.Severity),
+			Message:   sarifMessage{Text: f.message()},
+			Locations: []sarifLocation{
+				{PhysicalLocation: sarifPhysical(baseDir, f.Position)},
+			},
+			PartialFingerprints: map[string]string{
+				sarifFingerprint: fingerprint(f),
+			},
+		}
+
+		pkgs := f.affectedPackages()
+		if len(pkgs) > maxAffectedPackages {
+			pkgs = pkgs[:maxAffectedPackages]
+		}
+		for _, a := range pkgs {
+			result.RelatedLocations = append(result.RelatedLocations, sarifLocation{
+				PhysicalLocation: sarifPhysical(baseDir, a.Position),
+				Message:          &sarifMessage{Text: fmt.Sprintf("reached by package %s", a.Package)},
+			})
+		}
+
+		var flow []sarifThreadFlowLocation
+		for i := 1; i < len(f.Path); i++ {
+			if !f.Path[i].Position.IsValid() {
+				continue
+			}
+			flow = append(flow, sarifThreadFlowLocation{
+				Location: sarifLocation{
+					PhysicalLocation: sarifPhysical(baseDir, f.Path[i].Position),
+					Message:          &sarifMessage{Text: fmt.Sprintf("%s calls %s", f.Path[i-1].Function, f.Path[i].Function)},
+				},
+			})
+		}
+		if len(flow) > 0 {
+			result.CodeFlows = []sarifCodeFlow{
+				{ThreadFlows: []sarifThreadFlow{{Locations: flow}}},
+			}
+		}
+
+		run.Results = append(run.Results, result)
+	}
+
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(sarifLog{
+		Version: sarifVersion,
+		Schema:  sarifSchema,
+		Runs:    []sarifRun{run},
+	})
+}
+
+// sarifLevel returns the SARIF level of the severity.
+func sarifLevel(severity Severity) string {
+	switch severity {
+	case SeverityWarning:
+		return "warning"
+	case SeverityInfo:
+		return "note"
+	default:
+		return "error"
+	}
+}
+
+func sarifRuleID(capability string) string {
+	return "depcaps/" + capability
+}
+
+func sarifPhysical(baseDir string, pos token.Position) sarifPhysicalLocation {
+	loc := sarifPhysicalLocation{
+		ArtifactLocation: sarifArtifactLoc{URI: fileURI(pos.Filename)},
+	}
+	if rel, err := filepath.Rel(baseDir, pos.Filename); err == nil && !strings.HasPrefix(rel, "..") {
+		loc.ArtifactLocation = sarifArtifactLoc{
+			URI:       (&url.URL{Path: filepath.ToSlash(rel)}).String(),
+			URIBaseID: sarifSrcRoot,
+		}
+	}
+	if pos.Line > 0 {
+		loc.Region = &sarifRegion{StartLine: pos.Line, StartColumn: pos.Column}
+	}
+	return loc
+}
+
+func fileURI(filename string) string {
+	path := filepath.ToSlash(filename)
+	if !strings.HasPrefix(path, "/") {
+		// Windows drive letter
+		path = "/" + path
+	}
+	return (&url.URL{Scheme: "file", Path: path}).String()
+}
+
+// fingerprint identifies a finding independent of the line numbers and of the
+// internals of the dependency, such that it does not change with unrelated
+// changes of the code or updates of the dependency. Only the calling function
+// and the called function of the dependency are part of the fingerprint.
+func fingerprint(f Finding) string {
+	h := sha256.New()
+	// Problems are identified by their package and message.
+	if f.Problem != "" {
+		fmt.Fprintf(h, "%s\x00%s\x00%s", f.Package, problemRule, f.Problem)
+		return hex.EncodeToString(h.Sum(nil))
+	}
+	// Findings of modules are identified by the module instead.
+	if f.Unapproved || len(f.Affected) > 0 {
+		fmt.Fprintf(h, "%s\x00%s", f.Module, ruleName(f))
+		return hex.EncodeToString(h.Sum(nil))
+	}
+	fmt.Fprintf(h, "%s\x00%s\x00%s", f.Package, f.Dependency, f.Capability)
+	for i := 0; i < len(f.Path) && i < 2; i++ {
+		fmt.Fprintf(h, "\x00%s", f.Path[i].Function)
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+const (
+	// unapprovedRule is the name of the rule of the unapproved modules.
+	unapprovedRule = "UNAPPROVED_MODULE"
+	// problemRule is the name of the rule of the problems of the analysis.
+	problemRule = "ANALYSIS_PROBLEM"
+)
+
+// ruleName returns the name of the rule of f, which is the capability,
+// unapprovedRule for an unapproved module or problemRule for a problem.
+func ruleName(f Finding) string {
+	if f.Problem != "" {
+		return problemRule
+	}
+	if f.Unapproved {
+		return unapprovedRule
+	}
+	return f.Capability.String()
+}
+
+// findingRules returns the names of the rules of the findings, sorted and
+// without duplicates.
+func findingRules(findings []Finding) []string {
+	seen := make(map[string]bool)
+	var caps []string
+	for _, f := range findings {
+		c := ruleName(f)
+		if !seen[c] {
+			seen[c] = true
+			caps = append(caps, c)
+		}
+	}
+	sort.Strings(caps)
+	return caps
+}
@@ -0,0 +1,111 @@
+package depcaps_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/breml/depcaps/pkg/depcaps"
+)
+
+func TestWriteSARIF(t *testing.T) {
+	testCaseDir := chdirTestdata(t, "alltest")
+
+	findings, err := newLinter(t, nil, depcaps.WithCacheDir(t.TempDir())).Analyze(context.Background(), testCaseDir, "./simple/function")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	var buf bytes.Buffer
+	err = depcaps.WriteSARIF(&buf, testCaseDir, findings)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	var log struct {
+		Version string `json:"version"`
+		Runs    []struct {
+			Tool struct {
+				Driver struct {
+					Rules []struct {
+						ID string `json:"id"`
+					} `json:"rules"`
+				} `json:"driver"`
+			} `json:"tool"`
+			Results []struct {
+				RuleID    string `json:"ruleId"`
+				Locations []struct {
+					PhysicalLocation struct {
+						ArtifactLocation struct {
+							URI       string `json:"uri"`
+							URIBaseID string `json:"uriBaseId"`
+						} `json:"artifactLocation"`
+						Region struct {
+							StartLine int `json:"startLine"`
+						} `json:"region"`
+					} `json:"physicalLocation"`
+				} `json:"locations"`
+				CodeFlows []struct {
+					ThreadFlows []struct {
+						Locations []struct {
+							Location struct {
+								Message struct {
+									Text string `json:"text"`
+								} `json:"message"`
+							} `json:"location"`
+						} `json:"locations"`
+					} `json:"threadFlows"`
+				} `json:"codeFlows"`
+				PartialFingerprints map[string]string `json:"partialFingerprints"`
+			} `json:"results"`
+		} `json:"runs"`
+	}
+	err = json.Unmarshal(buf.Bytes(), &log)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if log.Version != "2.1.0" {
+		t.Fatalf("expected SARIF version 2.1.0, got: %s", log.Version)
+	}
+	if len(log.Runs) != 1 {
+		t.Fatalf("expected 1 run, got: %d", len(log.Runs))
+	}
+	run := log.Runs[0]
+
+	rules := make(map[string]bool)
+	for _, rule := range run.Tool.Driver.Rules {
+		rules[rule.ID] = true
+	}
+	if !rules["depcaps/CAPABILITY_NETWORK"] || !rules["depcaps/CAPABILITY_REFLECT"] {
+		t.Fatalf("expected rules for CAPABILITY_NETWORK and CAPABILITY_REFLECT, got: %v", rules)
+	}
+
+	for _, result := range run.Results {
+		if !rules[result.RuleID] {
+			t.Fatalf("result with unknown rule %s", result.RuleID)
+		}
+		if len(result.PartialFingerprints) == 0 {
+			t.Fatalf("expected partial fingerprints for result %s", result.RuleID)
+		}
+		if result.RuleID != "depcaps/CAPABILITY_NETWORK" {
+			continue
+		}
+
+		loc := result.Locations[0].PhysicalLocation
+		if loc.ArtifactLocation.URI != "simple/function/func_go122.go" || loc.ArtifactLocation.URIBaseID != "%SRCROOT%" || loc.Region.StartLine != 12 {
+			t.Fatalf("expected location at simple/function/func_go122.go:12, got: %+v", loc)
+		}
+		if len(result.CodeFlows) == 0 || len(result.CodeFlows[0].ThreadFlows) == 0 || len(result.CodeFlows[0].ThreadFlows[0].Locations) == 0 {
+			t.Fatalf("expected code flow for result %s", result.RuleID)
+		}
+		msg := result.CodeFlows[0].ThreadFlows[0].Locations[0].Location.Message.Text
+		if msg != "alltest/simple/function.Call calls github.com/google/uuid.GetTime" {
+			t.Fatalf("unexpected message of first code flow location: %q", msg)
+		}
+		return
+	}
+
+	t.Fatalf("expected result for CAPABILITY_NETWORK")
+}
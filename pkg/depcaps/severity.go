@@ -0,0 +1,91 @@
+package depcaps
+
+import (
+	"fmt"
+
+	"github.com/google/capslock/proto"
+)
+
+// Severity is the severity of a not allowed capability.
+type Severity int
+
+const (
+	// SeverityNone is the severity of allowed capabilities.
+	SeverityNone Severity = iota
+	SeverityInfo
+	SeverityWarning
+	SeverityError
+)
+
+var severityNames = map[Severity]string{
+	SeverityNone:    "none",
+	SeverityInfo:    "info",
+	SeverityWarning: "warning",
+	SeverityError:   "error",
+}
+
+func (s Severity) String() string {
+	return severityNames[s]
+}
+
+func (s Severity) MarshalText() ([]byte, error) {
+	name, ok := severityNames[s]
+	if !ok {
+		return nil, fmt.Errorf("invalid severity %d", int(s))
+	}
+	return []byte(name), nil
+}
+
+func (s *Severity) UnmarshalText(text []byte) error {
+	severity, err := ParseSeverity(string(text))
+	if err != nil {
+		return err
+	}
+	*s = severity
+	return nil
+}
+
+// ParseSeverity returns the severity with the given name, which is one of
+// error, warning and info.
+func ParseSeverity(name string) (Severity, error) {
+	for severity, n := range severityNames {
+		if n == name && severity != SeverityNone {
+			return severity, nil
+		}
+	}
+	return SeverityNone, fmt.Errorf("invalid severity %q, expected error, warning or info", name)
+}
+
+// HighestSeverity returns the highest severity of the findings.
+func HighestSeverity(findings []Finding) Severity {
+	highest := SeverityNone
+	for _, f := range findings {
+		if f.Severity > highest {
+			highest = f.Severity
+		}
+	}
+	return highest
+}
+
+// denied returns the severity, with which the capability c of depPkg is
+// denied. Package rules take precedence over global rules.
+func (s *LinterSettings) denied(depPkg string, c proto.Capability) (Severity, bool) {
+	if severity, ok := s.PackageDeniedCapabilities[s.packageKey(depPkg)][c.String()]; ok {
+		return severity, true
+	}
+	severity, ok := s.GlobalDeniedCapabilities[c.String()]
+	return severity, ok
+}
+
+// severity returns the severity of the not allowed capability c of depPkg.
+// Package rules take precedence over global rules, without a rule, the
+// severity is error.
+func (s *LinterSettings) severity(depPkg string, c proto.Capability) Severity {
+	if severity, ok := s.PackageCapabilitySeverity[s.packageKey(depPkg)][c.String()]; ok {
+		return severity
+	}
+	if severity, ok := s.GlobalCapabilitySeverity[c.String()]; ok {
+		return severity
+	}
+	return SeverityError
+}
@@ -0,0 +1,89 @@
+package depcaps_test
+
+import (
+	"bytes"
+	"context"
+	"testing"
+
+	"github.com/breml/depcaps/pkg/depcaps"
+)
+
+func TestSeverity(t *testing.T) {
+	testCaseDir := chdirTestdata(t, "alltest")
+
+	settings := &depcaps.LinterSettings{
+		GlobalAllowedCapabilities: map[string]bool{
+			"CAPABILITY_REFLECT": true,
+		},
+		GlobalCapabilitySeverity: map[string]depcaps.Severity{
+			"CAPABILITY_NETWORK": depcaps.SeverityInfo,
+		},
+		PackageCapabilitySeverity: map[string]map[string]depcaps.Severity{
+			"github.com/google/uuid": {
+				"CAPABILITY_NETWORK": depcaps.SeverityWarning,
+			},
+		},
+		GlobalDeniedCapabilities: map[string]depcaps.Severity{
+			"CAPABILITY_REFLECT": depcaps.SeverityError,
+		},
+	}
+	findings, err := newLinter(t, settings, depcaps.WithCacheDir(t.TempDir())).Analyze(context.Background(), testCaseDir, "./allow/...")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	severities := make(map[string]depcaps.Severity)
+	for _, f := range depcaps.Violations(findings) {
+		severities[f.Capability.String()] = f.Severity
+	}
+
+	// The package severity takes precedence over the global severity.
+	if severities["CAPABILITY_NETWORK"] != depcaps.SeverityWarning {
+		t.Fatalf("expected CAPABILITY_NETWORK with severity warning, got: %v", severities)
+	}
+	// Denied capabilities are reported, even if they are allowed.
+	if severities["CAPABILITY_REFLECT"] != depcaps.SeverityError {
+		t.Fatalf("expected denied CAPABILITY_REFLECT with severity error, got: %v", severities)
+	}
+
+	var buf bytes.Buffer
+	err = depcaps.WriteText(&buf, findings)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !bytes.Contains(buf.Bytes(), []byte(": warning: Package github.com/google/uuid has not allowed capability CAPABILITY_NETWORK")) {
+		t.Fatalf("expected warning in text output, got:\n%s", buf.String())
+	}
+}
+
+func TestHighestSeverity(t *testing.T) {
+	findings := []depcaps.Finding{
+		{Decision: depcaps.AllowedGlobal},
+		{Decision: depcaps.Violation, Severity: depcaps.SeverityInfo},
+		{Decision: depcaps.Violation, Severity: depcaps.SeverityWarning},
+	}
+	if got := depcaps.HighestSeverity(findings); got != depcaps.SeverityWarning {
+		t.Fatalf("expected highest severity warning, got: %s", got)
+	}
+	if got := depcaps.HighestSeverity(nil); got != depcaps.SeverityNone {
+		t.Fatalf("expected no severity without findings, got: %s", got)
+	}
+}
+
+func TestParseSeverity(t *testing.T) {
+	for _, name := range []string{"error", "warning", "info"} {
+		severity, err := depcaps.ParseSeverity(name)
+		if err != nil {
+			t.Fatalf("unexpected error for %q: %v", name, err)
+		}
+		if severity.String() != name {
+			t.Fatalf("expected severity %q, got: %q", name, severity)
+		}
+	}
+	for _, name := range []string{"none", "critical", ""} {
+		_, err := depcaps.ParseSeverity(name)
+		if err == nil {
+			t.Fatalf("expected error for %q", name)
+		}
+	}
+}
@@ -0,0 +1,719 @@
+package depcaps
+
+import (
+	"go/token"
+	"go/types"
+	"sort"
+	"strings"
+
+	"github.com/google/capslock/analyzer"
+	"github.com/google/capslock/proto"
+	"golang.org/x/tools/go/analysis"
+	"golang.org/x/tools/go/callgraph"
+	"golang.org/x/tools/go/ssa"
+	pb "google.golang.org/protobuf/proto"
+)
+
+// edge is a call (or a reference to a function value) from a function to
+// callee.
+type edge struct {
+	callee *ssa.Function
+	pos    token.Pos
+}
+
+// summary holds the capabilities of the functions of a single package.
+//
+// The capabilities of a function are either assigned directly by the
+// classifier or are gained by calling other functions. The capabilities of
+// functions of other packages are taken from the facts of these packages.
+//
+// Static calls and references to function values are considered as calls.
+// Dynamic calls of interface methods are resolved to the methods of the
+// concrete types, which flow to the receiver of the call in the type flow
+// graph of the package and its dependencies and implement the interface. The
+// types of the packages depending on the package are not known yet.
+// Therefore the dynamic calls are recorded in the fact together with the type
+// flow graph of the package and are resolved against the types flowing to
+// their receivers in the whole program by the packages of the main module,
+// like capslock does (see resolveProgram).
+type summary struct {
+	pass       *analysis.Pass
+	classifier analyzer.Classifier
+	facts      map[string]*packageCapabilities
+
+	// implementations holds the concrete types of the package and its
+	// dependencies by the names of their methods. Only the types in
+	// siteTypes, which flow to the receiver of a dynamic call, are
+	// considered as implementations of the interface of the call.
+	implementations map[string][]types.Type
+	resolved        map[invoke][]*ssa.Function
+	siteTypes       map[string][]string
+
+	// parts holds the parts of the program summaries of the dependencies,
+	// types their converted types and ownConverted the types converted by
+	// the package. flows holds the type flow graph of the package, invokes
+	// the dynamic calls of the functions and program the capabilities of the
+	// functions of the dependencies gained through the types of the whole
+	// program.
+	parts        map[string]*programPackage
+	types        map[string]*programType
+	ownConverted []types.Type
+	flows        map[string]*typeFlow
+	invokes      map[*ssa.Function][]invokeSite
+	program      map[string]map[proto.Capability]*proto.CapabilityInfo
+	prog         *ssa.Program
+
+	// functions holds the functions of the package, nodes additionally the
+	// called functions of other packages.
+	functions    []*ssa.Function
+	nodes        []*ssa.Function
+	names        map[*ssa.Function]string
+	byName       map[string]*ssa.Function
+	edges        map[*ssa.Function][]edge
+	capabilities map[*ssa.Function]map[proto.Capability]*proto.CapabilityInfo
+}
+
+// invoke is a dynamic call of method of the interface iface, whose receiver
+// is the node of the type flow graph.
+type invoke struct {
+	node   string
+	iface  *types.Interface
+	method string
+}
+
+// invokeSite is a dynamic call together with the node of its receiver in the
+// type flow graph.
+type invokeSite struct {
+	node string
+	call dynamicCall
+}
+
+// summarize computes the capabilities of the functions of the package
+// analyzed by pass. If program is set, the dynamic calls of the dependencies
+// are resolved against the types of the whole program first.
+func summarize(pass *analysis.Pass, classifier analyzer.Classifier, program bool) *summary {
+	s := &summary{
+		pass:         pass,
+		classifier:   classifier,
+		facts:        make(map[string]*packageCapabilities),
+		resolved:     make(map[invoke][]*ssa.Function),
+		invokes:      make(map[*ssa.Function][]invokeSite),
+		program:      make(map[string]map[proto.Capability]*proto.CapabilityInfo),
+		names:        make(map[*ssa.Function]string),
+		byName:       make(map[string]*ssa.Function),
+		edges:        make(map[*ssa.Function][]edge),
+		capabilities: make(map[*ssa.Function]map[proto.Capability]*proto.CapabilityInfo),
+	}
+
+	for _, f := range pass.AllPackageFacts() {
+		if fact, ok := f.Fact.(*packageCapabilities); ok {
+			s.facts[f.Package.Path()] = fact
+		}
+	}
+
+	ssapkg := s.buildSSA()
+	s.collectTypes(ssapkg)
+	s.flows = flowSummary(ssapkg)
+	s.siteTypes = s.flowTypes()
+	if program {
+		s.resolveProgram()
+	}
+
+	s.collectFunctions(ssapkg)
+	s.propagate()
+
+	return s
+}
+
+func (s *summary) buildSSA() *ssa.Package {
+	prog := ssa.NewProgram(s.pass.Fset, ssa.InstantiateGenerics)
+
+	// Create the SSA packages for all the dependencies from type information.
+	created := map[*types.Package]bool{s.pass.Pkg: true}
+	var createImports func(p *types.Package)
+	createImports = func(p *types.Package) {
+		for _, imp := range p.Imports() {
+			if created[imp] {
+				continue
+			}
+			created[imp] = true
+			prog.CreatePackage(imp, nil, nil, true)
+			createImports(imp)
+		}
+	}
+	createImports(s.pass.Pkg)
+
+	ssapkg := prog.CreatePackage(s.pass.Pkg, s.pass.Files, s.pass.TypesInfo, false)
+	ssapkg.Build()
+
+	s.prog = prog
+	s.collectImplementations(prog, created)
+
+	return ssapkg
+}
+
+// collectImplementations indexes the concrete package level types of pkgs by
+// the names of their methods.
+func (s *summary) collectImplementations(prog *ssa.Program, pkgs map[*types.Package]bool) {
+	s.implementations = make(map[string][]types.Type)
+	for pkg := range pkgs {
+		scope := pkg.Scope()
+		for _, name := range scope.Names() {
+			tn, ok := scope.Lookup(name).(*types.TypeName)
+			if !ok || tn.IsAlias() {
+				continue
+			}
+			named, ok := tn.Type().(*types.Named)
+			if !ok || named.TypeParams().Len() > 0 || types.IsInterface(named) {
+				continue
+			}
+			for _, t := range []types.Type{named, types.NewPointer(named)} {
+				mset := prog.MethodSets.MethodSet(t)
+				for i := 0; i < mset.Len(); i++ {
+					id := mset.At(i).Obj().Id()
+					s.implementations[id] = append(s.implementations[id], t)
+				}
+			}
+		}
+	}
+
+	// The packages are visited in random order, sort the types for stable
+	// results.
+	for _, ts := range s.implementations {
+		sort.Slice(ts, func(i, j int) bool {
+			return ts[i].String() < ts[j].String()
+		})
+	}
+}
+
+// implementationsOf returns the methods, a dynamic call of method of iface
+// with the receiver node might be dispatched to.
+func (s *summary) implementationsOf(prog *ssa.Program, node string, iface *types.Interface, method *types.Func) []*ssa.Function {
+	key := invoke{node: node, iface: iface, method: method.Id()}
+	if fns, ok := s.resolved[key]; ok {
+		return fns
+	}
+
+	flowing := make(map[string]bool, len(s.siteTypes[node]))
+	for _, name := range s.siteTypes[node] {
+		flowing[name] = true
+	}
+
+	var fns []*ssa.Function
+	for _, t := range s.implementations[method.Id()] {
+		if !flowing[types.TypeString(t, nil)] || !types.Implements(t, iface) {
+			continue
+		}
+		sel := prog.MethodSets.MethodSet(t).Lookup(method.Pkg(), method.Name())
+		if sel == nil {
+			continue
+		}
+		if fn := prog.MethodValue(sel); fn != nil {
+			fns = append(fns, fn)
+		}
+	}
+
+	s.resolved[key] = fns
+	return fns
+}
+
+// collectFunctions collects the functions of the package and the functions
+// of other packages reachable from them together with their outgoing edges
+// and their direct capabilities.
+func (s *summary) collectFunctions(ssapkg *ssa.Package) {
+	var queue []*ssa.Function
+	seen := make(map[*ssa.Function]bool)
+	enqueue := func(fn *ssa.Function) {
+		if fn == nil || seen[fn] {
+			return
+		}
+		if s.isLocal(fn) || len(fn.Blocks) == 0 {
+			seen[fn] = true
+			queue = append(queue, fn)
+		}
+	}
+
+	for _, member := range ssapkg.Members {
+		switch member := member.(type) {
+		case *ssa.Function:
+			enqueue(member)
+		case *ssa.Type:
+			named, ok := member.Type().(*types.Named)
+			if !ok {
+				continue
+			}
+			for i := 0; i < named.NumMethods(); i++ {
+				enqueue(ssapkg.Prog.FuncValue(named.Method(i)))
+			}
+		}
+	}
+
+	for len(queue) > 0 {
+		fn := queue[0]
+		queue = queue[1:]
+
+		if s.isLocal(fn) {
+			s.collectFunction(fn)
+		} else {
+			s.collectForeignFunction(fn)
+		}
+		s.nodes = append(s.nodes, fn)
+		s.byName[s.names[fn]] = fn
+
+		if s.isLocal(fn) {
+			for _, anon := range fn.AnonFuncs {
+				enqueue(anon)
+			}
+		}
+		for _, e := range s.edges[fn] {
+			enqueue(e.callee)
+		}
+	}
+
+	sortFunctions(s.functions)
+}
+
+// packageFunctions returns the functions of ssapkg including the methods and
+// the anonymous functions.
+func packageFunctions(ssapkg *ssa.Package) []*ssa.Function {
+	var fns []*ssa.Function
+	var add func(fn *ssa.Function)
+	add = func(fn *ssa.Function) {
+		if fn == nil {
+			return
+		}
+		fns = append(fns, fn)
+		for _, anon := range fn.AnonFuncs {
+			add(anon)
+		}
+	}
+
+	for _, member := range ssapkg.Members {
+		switch member := member.(type) {
+		case *ssa.Function:
+			add(member)
+		case *ssa.Type:
+			named, ok := member.Type().(*types.Named)
+			if !ok {
+				continue
+			}
+			for i := 0; i < named.NumMethods(); i++ {
+				add(ssapkg.Prog.FuncValue(named.Method(i)))
+			}
+		}
+	}
+
+	return fns
+}
+
+func (s *summary) collectFunction(fn *ssa.Function) {
+	s.functions = append(s.functions, fn)
+	s.names[fn] = fn.String()
+	s.capabilities[fn] = make(map[proto.Capability]*proto.CapabilityInfo)
+
+	switch c := s.category(fn); c {
+	case proto.Capability_CAPABILITY_SAFE:
+	case proto.Capability_CAPABILITY_UNSPECIFIED:
+		s.edges[fn] = s.collectEdges(fn, make(map[*ssa.Function]bool))
+		if hasUnsafePointerConversion(fn) {
+			s.capabilities[fn][proto.Capability_CAPABILITY_UNSAFE_POINTER] = s.capabilityInfo(fn, proto.Capability_CAPABILITY_UNSAFE_POINTER, nil)
+		}
+	default:
+		// Functions with an explicit capability are not analyzed any further.
+		s.capabilities[fn][c] = s.capabilityInfo(fn, c, nil)
+	}
+}
+
+// collectForeignFunction collects a function of an other package. Its
+// capabilities are taken from the fact of its package.
+func (s *summary) collectForeignFunction(fn *ssa.Function) {
+	s.names[fn] = fn.String()
+	s.capabilities[fn] = make(map[proto.Capability]*proto.CapabilityInfo)
+
+	pkg := functionPackage(fn)
+	if pkg == nil {
+		return
+	}
+
+	fact, ok := s.facts[pkg.Path()]
+	if !ok {
+		// Without a fact for the package, only the classification of the
+		// function is known.
+		c := s.category(fn)
+		if c != proto.Capability_CAPABILITY_SAFE && c != proto.Capability_CAPABILITY_UNSPECIFIED {
+			s.capabilities[fn][c] = s.capabilityInfo(fn, c, nil)
+		}
+		return
+	}
+
+	if fact.function(fn.String()) == nil && s.program[fn.String()] == nil && fn.Origin() != nil {
+		// Capabilities of instances of generic functions are recorded for the
+		// generic function.
+		s.names[fn] = fn.Origin().String()
+	}
+
+	for capability, ci := range fact.function(s.names[fn]) {
+		s.capabilities[fn][capability] = ci
+	}
+	for capability, ci := range s.program[s.names[fn]] {
+		s.capabilities[fn][capability] = ci
+	}
+}
+
+// collectEdges returns the outgoing edges of fn in a stable order. Synthetic
+// functions of other packages are resolved to the functions they call.
+func (s *summary) collectEdges(fn *ssa.Function, visited map[*ssa.Function]bool) []edge {
+	visited[fn] = true
+	s.invokes[fn] = nil
+
+	var edges []edge
+	var buf [10]*ssa.Value
+	sites := 0
+	for _, b := range fn.Blocks {
+		for _, instr := range b.Instrs {
+			var callees []*ssa.Function
+			if call, ok := instr.(ssa.CallInstruction); ok && call.Common().IsInvoke() {
+				node := siteNode(fn, sites)
+				iface := call.Common().Value.Type().Underlying().(*types.Interface)
+				callees = append(callees, s.implementationsOf(fn.Prog, node, iface, call.Common().Method)...)
+				s.invokes[fn] = append(s.invokes[fn], invokeSite{node: node, call: newDynamicCall(iface, call.Common().Method)})
+				sites++
+			}
+			for _, op := range instr.Operands(buf[:0]) {
+				if callee, ok := (*op).(*ssa.Function); ok {
+					callees = append(callees, callee)
+				}
+			}
+
+			for _, callee := range callees {
+				edges = append(edges, s.inline(fn, callee, instr.Pos(), visited)...)
+			}
+		}
+	}
+
+	return edges
+}
+
+// inline returns the edges for a call from fn to callee. Synthetic functions
+// of other packages with a body, e.g. wrappers or instances of generic
+// functions, are replaced by the functions they call.
+func (s *summary) inline(fn, callee *ssa.Function, pos token.Pos, visited map[*ssa.Function]bool) []edge {
+	if !s.includeCall(fn, callee) {
+		return nil
+	}
+
+	if len(callee.Blocks) == 0 || s.isLocal(callee) {
+		return []edge{{callee: callee, pos: pos}}
+	}
+
+	if visited[callee] {
+		return nil
+	}
+
+	var edges []edge
+	for _, e := range s.collectEdges(callee, visited) {
+		edges = append(edges, edge{callee: e.callee, pos: pos})
+	}
+	s.invokes[fn] = append(s.invokes[fn], s.invokes[callee]...)
+	return edges
+}
+
+func (s *summary) isLocal(fn *ssa.Function) bool {
+	return len(fn.Blocks) > 0 && functionPackage(fn) == s.pass.Pkg
+}
+
+func (s *summary) includeCall(caller, callee *ssa.Function) bool {
+	return s.classifier.IncludeCall(&callgraph.Edge{
+		Caller: &callgraph.Node{Func: caller},
+		Callee: &callgraph.Node{Func: callee},
+	})
+}
+
+// category returns the capability, the classifier assigns to fn.
+func (s *summary) category(fn *ssa.Function) proto.Capability {
+	pkg := functionPackage(fn)
+	if pkg == nil {
+		return proto.Capability_CAPABILITY_UNSPECIFIED
+	}
+	if fn.Origin() != nil {
+		fn = fn.Origin()
+	}
+	return s.classifier.FunctionCategory(pkg.Path(), fn.String())
+}
+
+// propagate propagates the capabilities along the edges until a fixed point
+// is reached. Capabilities are propagated one edge per round, such that every
+// function reaches its capabilities with the least possible number of calls.
+func (s *summary) propagate() {
+	type update struct {
+		fn         *ssa.Function
+		capability proto.Capability
+		edge       edge
+	}
+	type fnCapability struct {
+		fn         *ssa.Function
+		capability proto.Capability
+	}
+
+	for {
+		var updates []update
+		queued := make(map[fnCapability]bool)
+		for _, fn := range s.nodes {
+			for _, e := range s.edges[fn] {
+				if e.callee == fn {
+					continue
+				}
+				for capability := range s.capabilities[e.callee] {
+					fc := fnCapability{fn: fn, capability: capability}
+					if _, ok := s.capabilities[fn][capability]; ok || queued[fc] {
+						continue
+					}
+					queued[fc] = true
+					updates = append(updates, update{fn: fn, capability: capability, edge: e})
+				}
+			}
+		}
+
+		if len(updates) == 0 {
+			return
+		}
+
+		for _, u := range updates {
+			s.capabilities[u.fn][u.capability] = s.capabilityInfo(u.fn, u.capability, s.calleeFunction(u.edge))
+		}
+	}
+}
+
+// lookup returns the capabilities of the function with the given name in the
+// package with the given path.
+func (s *summary) lookup(pkgPath, name string) map[proto.Capability]*proto.CapabilityInfo {
+	if fn, ok := s.byName[name]; ok {
+		return s.capabilities[fn]
+	}
+
+	var caps map[proto.Capability]*proto.CapabilityInfo
+	if fact, ok := s.facts[pkgPath]; ok {
+		caps = fact.function(name)
+	}
+	if extra := s.program[name]; len(extra) > 0 {
+		merged := make(map[proto.Capability]*proto.CapabilityInfo, len(caps)+len(extra))
+		for capability, ci := range caps {
+			merged[capability] = ci
+		}
+		for capability, ci := range extra {
+			merged[capability] = ci
+		}
+		caps = merged
+	}
+
+	return caps
+}
+
+// expand returns the full path of ci, by following the callees through the
+// capabilities of their packages.
+func (s *summary) expand(ci *proto.CapabilityInfo) []*proto.Function {
+	path := []*proto.Function{ci.GetPath()[0]}
+	visited := map[string]bool{ci.GetPath()[0].GetName(): true}
+	for len(ci.GetPath()) > 1 {
+		next := ci.GetPath()[1]
+		path = append(path, next)
+		if visited[next.GetName()] {
+			break
+		}
+		visited[next.GetName()] = true
+
+		ci = s.lookup(next.GetPackage(), next.GetName())[ci.GetCapability()]
+		if ci == nil {
+			break
+		}
+	}
+	return path
+}
+
+// fact returns the fact for the package, holding the capabilities of the
+// functions of the package.
+func (s *summary) fact() *packageCapabilities {
+	cil := &proto.CapabilityInfoList{}
+	for _, fn := range s.functions {
+		for _, capability := range sortedCapabilities(s.capabilities[fn]) {
+			cil.CapabilityInfo = append(cil.CapabilityInfo, s.capabilities[fn][capability])
+		}
+	}
+	if s.pass.Module != nil {
+		cil.ModuleInfo = []*proto.ModuleInfo{
+			{
+				Path:    pb.String(s.pass.Module.Path),
+				Version: pb.String(s.pass.Module.Version),
+			},
+		}
+	}
+	fact := newPackageCapabilities(cil)
+	fact.program = s.programSummary()
+	return fact
+}
+
+// dependencyCalls returns the capabilities, the functions of the package
+// gain by directly calling functions of the packages, for which isDependency
+// returns true. The returned capability infos contain the full path and have
+// the same form as the ones produced by capslock.
+func (s *summary) dependencyCalls(isDependency func(pkgPath string) bool) []*proto.CapabilityInfo {
+	type key struct {
+		caller     *ssa.Function
+		depPackage string
+		capability proto.Capability
+	}
+	seen := make(map[key]bool)
+
+	var cis []*proto.CapabilityInfo
+	for _, fn := range s.functions {
+		for _, e := range s.edges[fn] {
+			pkg := functionPackage(e.callee)
+			if pkg == nil || pkg == s.pass.Pkg || !isDependency(pkg.Path()) {
+				continue
+			}
+
+			calleeCapabilities := s.capabilities[e.callee]
+			for _, capability := range sortedCapabilities(calleeCapabilities) {
+				k := key{caller: fn, depPackage: pkg.Path(), capability: capability}
+				if seen[k] {
+					continue
+				}
+				seen[k] = true
+
+				path := []*proto.Function{s.function(fn), s.calleeFunction(e)}
+				path = append(path, s.expand(calleeCapabilities[capability])[1:]...)
+
+				names := make([]string, 0, len(path))
+				for _, fn := range path {
+					names = append(names, fn.GetName())
+				}
+
+				cis = append(cis, &proto.CapabilityInfo{
+					PackageName:    pb.String(s.pass.Pkg.Name()),
+					Capability:     capability.Enum(),
+					DepPath:        pb.String(strings.Join(names, " ")),
+					Path:           path,
+					PackageDir:     pb.String(s.pass.Pkg.Path()),
+					CapabilityType: proto.CapabilityType_CAPABILITY_TYPE_TRANSITIVE.Enum(),
+				})
+			}
+		}
+	}
+
+	return cis
+}
+
+// capabilityInfo returns the capability info for fn, which gains capability
+// by calling callee. If callee is nil, fn has the capability itself.
+func (s *summary) capabilityInfo(fn *ssa.Function, capability proto.Capability, callee *proto.Function) *proto.CapabilityInfo {
+	pkg := functionPackage(fn)
+
+	path := []*proto.Function{s.function(fn)}
+	capabilityType := proto.CapabilityType_CAPABILITY_TYPE_DIRECT
+	if callee != nil {
+		path = append(path, callee)
+		if callee.GetPackage() != pkg.Path() && !isStdLib(callee.GetPackage()) {
+			capabilityType = proto.CapabilityType_CAPABILITY_TYPE_TRANSITIVE
+		}
+		if next := s.lookup(callee.GetPackage(), callee.GetName())[capability]; next.GetCapabilityType() == proto.CapabilityType_CAPABILITY_TYPE_TRANSITIVE {
+			capabilityType = proto.CapabilityType_CAPABILITY_TYPE_TRANSITIVE
+		}
+	}
+
+	return &proto.CapabilityInfo{
+		PackageName:    pb.String(pkg.Name()),
+		Capability:     capability.Enum(),
+		Path:           path,
+		PackageDir:     pb.String(pkg.Path()),
+		CapabilityType: capabilityType.Enum(),
+	}
+}
+
+func (s *summary) function(fn *ssa.Function) *proto.Function {
+	return &proto.Function{
+		Name:    pb.String(fn.String()),
+		Package: pb.String(functionPackage(fn).Path()),
+	}
+}
+
+// calleeFunction returns the callee of e including the call site. In contrast
+// to capslock, the file name of the call site is the full path, such that the
+// call site can be located later on.
+func (s *summary) calleeFunction(e edge) *proto.Function {
+	fn := &proto.Function{
+		Name:    pb.String(s.names[e.callee]),
+		Package: pb.String(functionPackage(e.callee).Path()),
+	}
+
+	if position := s.pass.Fset.Position(e.pos); position.IsValid() {
+		fn.Site = &proto.Function_Site{
+			Filename: pb.String(position.Filename),
+			Line:     pb.Int64(int64(position.Line)),
+			Column:   pb.Int64(int64(position.Column)),
+		}
+	}
+
+	return fn
+}
+
+// hasUnsafePointerConversion reports, if fn converts an unsafe.Pointer to
+// an other type than uintptr.
+func hasUnsafePointerConversion(fn *ssa.Function) bool {
+	for _, b := range fn.Blocks {
+		for _, instr := range b.Instrs {
+			conv, ok := instr.(*ssa.Convert)
+			if !ok {
+				continue
+			}
+			if from, ok := conv.X.Type().Underlying().(*types.Basic); !ok || from.Kind() != types.UnsafePointer {
+				continue
+			}
+			if to, ok := conv.Type().Underlying().(*types.Basic); ok && (to.Kind() == types.Uintptr || to.Kind() == types.UnsafePointer) {
+				continue
+			}
+			return true
+		}
+	}
+	return false
+}
+
+// functionPackage returns the package, fn belongs to. For instances of
+// generic functions, this is the package of the generic function and for
+// wrappers of methods, this is the package of the method.
+func functionPackage(fn *ssa.Function) *types.Package {
+	if fn.Origin() != nil {
+		fn = fn.Origin()
+	}
+	if fn.Pkg != nil && fn.Pkg.Pkg != nil {
+		return fn.Pkg.Pkg
+	}
+	if obj := fn.Object(); obj != nil {
+		return obj.Pkg()
+	}
+	return nil
+}
+
+func sortFunctions(fns []*ssa.Function) {
+	sort.Slice(fns, func(i, j int) bool {
+		if fns[i].Pos() != fns[j].Pos() {
+			return fns[i].Pos() < fns[j].Pos()
+		}
+		return fns[i].String() < fns[j].String()
+	})
+}
+
+func sortedCapabilities(m map[proto.Capability]*proto.CapabilityInfo) []proto.Capability {
+	capabilities := make([]proto.Capability, 0, len(m))
+	for capability := range m {
+		capabilities = append(capabilities, capability)
+	}
+	sort.Slice(capabilities, func(i, j int) bool {
+		return capabilities[i] < capabilities[j]
+	})
+	return capabilities
+}
+
+func isStdLib(pkgPath string) bool {
+	first, _, _ := strings.Cut(pkgPath, "/")
+	return !strings.Contains(first, ".")
+}
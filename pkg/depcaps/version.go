@@ -1,8 +1,11 @@
 package depcaps
 
 import (
+	"crypto/sha256"
 	"fmt"
+	"io"
 	"os"
+	"path/filepath"
 )
 
 var Version = "depcaps version dev"
@@ -13,7 +16,35 @@ func (versionFlag) IsBoolFlag() bool { return true }
 func (versionFlag) Get() interface{} { return nil }
 func (versionFlag) String() string   { return "" }
 func (versionFlag) Set(s string) error {
+	if s == "full" {
+		// The go command (go vet -vettool) identifies the tool by the output of
+		// -V=full and caches the facts of the analyzed packages by this
+		// identity. Therefore the output needs to change with every build of
+		// the tool, otherwise stale facts are used.
+		return printFullVersion()
+	}
+
 	fmt.Println(Version)
 	os.Exit(0)
 	return nil
 }
+
+func printFullVersion() error {
+	progname, err := os.Executable()
+	if err != nil {
+		return err
+	}
+	f, err := os.Open(progname)
+	if err != nil {
+		return err
+	}
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return err
+	}
+	_ = f.Close()
+
+	fmt.Printf("%s version devel comments-go-here buildID=%02x\n", filepath.Base(progname), string(h.Sum(nil)))
+	os.Exit(0)
+	return nil
+}
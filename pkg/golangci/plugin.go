@@ -0,0 +1,131 @@
+// Package golangci provides depcaps as golangci-lint module plugin.
+package golangci
+
+import (
+	"errors"
+	"strings"
+
+	"github.com/golangci/plugin-module-register/register"
+	"golang.org/x/tools/go/analysis"
+
+	"github.com/breml/depcaps/pkg/depcaps"
+)
+
+func init() {
+	register.Plugin("depcaps", New)
+}
+
+// Settings are the settings of depcaps in the golangci-lint config.
+type Settings struct {
+	depcaps.LinterSettings
+
+	// Config is the path of a depcaps config JSON file. It can not be combined
+	// with the settings of depcaps.LinterSettings.
+	Config string `json:"Config"`
+	// CapslockBaselineFile is the path of the capslock reference file.
+	CapslockBaselineFile string `json:"CapslockBaselineFile"`
+	// FailUnplaceable fails the analysis, if a finding can neither be placed
+	// at a call site nor at an import.
+	FailUnplaceable bool `json:"FailUnplaceable"`
+	// CacheDir is the directory of the capabilities cache.
+	CacheDir string `json:"CacheDir"`
+}
+
+type plugin struct {
+	settings Settings
+}
+
+// New returns the depcaps plugin for the settings from the golangci-lint
+// config.
+func New(conf any) (register.LinterPlugin, error) {
+	settings, err := register.DecodeSettings[Settings](conf)
+	if err != nil {
+		return nil, err
+	}
+
+	if settings.Config != "" {
+		if !isEmpty(settings.LinterSettings) {
+			return nil, errors.New("depcaps: Config can not be combined with inline settings")
+		}
+		err = settings.LinterSettings.Set(settings.Config)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	normalize(&settings.LinterSettings)
+	err = settings.LinterSettings.Validate()
+	if err != nil {
+		return nil, err
+	}
+
+	return &plugin{settings: settings}, nil
+}
+
+func (p *plugin) BuildAnalyzers() ([]*analysis.Analyzer, error) {
+	opts := []depcaps.Option{
+		depcaps.WithCacheDir(p.settings.CacheDir),
+		depcaps.WithFailUnplaceable(p.settings.FailUnplaceable),
+	}
+	if p.settings.CapslockBaselineFile != "" {
+		opts = append(opts, depcaps.WithBaselineFile(p.settings.CapslockBaselineFile))
+	}
+	if p.settings.Config == "" {
+		// The packages of the inline settings are in lower case, only the
+		// config file keeps the case of the package paths.
+		opts = append(opts, depcaps.WithCaseInsensitivePackages())
+	}
+
+	d, err := depcaps.New(&p.settings.LinterSettings, opts...)
+	if err != nil {
+		return nil, err
+	}
+
+	return []*analysis.Analyzer{d.AsAnalyzer(false)}, nil
+}
+
+// GetLoadMode returns the load mode types info, since depcaps depends on the
+// type information and the facts of the dependencies.
+func (p *plugin) GetLoadMode() string {
+	return register.LoadModeTypesInfo
+}
+
+func isEmpty(s depcaps.LinterSettings) bool {
+	return len(s.GlobalAllowedCapabilities) == 0 &&
+		len(s.PackageAllowedCapabilities) == 0 &&
+		len(s.GlobalCapabilitySeverity) == 0 &&
+		len(s.PackageCapabilitySeverity) == 0 &&
+		len(s.GlobalDeniedCapabilities) == 0 &&
+		len(s.PackageDeniedCapabilities) == 0 &&
+		!s.RequireExplicitApproval &&
+		!s.RequireExplicitApprovalIndirect
+}
+
+// normalize converts the capabilities to upper case, since golangci-lint
+// converts all the keys of the settings to lower case. The packages are
+// matched case-insensitively instead.
+func normalize(s *depcaps.LinterSettings) {
+	s.GlobalAllowedCapabilities = upperKeys(s.GlobalAllowedCapabilities)
+	for pkg, caps := range s.PackageAllowedCapabilities {
+		s.PackageAllowedCapabilities[pkg] = upperKeys(caps)
+	}
+	s.GlobalCapabilitySeverity = upperKeys(s.GlobalCapabilitySeverity)
+	for pkg, caps := range s.PackageCapabilitySeverity {
+		s.PackageCapabilitySeverity[pkg] = upperKeys(caps)
+	}
+	s.GlobalDeniedCapabilities = upperKeys(s.GlobalDeniedCapabilities)
+	for pkg, caps := range s.PackageDeniedCapabilities {
+		s.PackageDeniedCapabilities[pkg] = upperKeys(caps)
+	}
+}
+
+func upperKeys[V any](m map[string]V) map[string]V {
+	if m == nil {
+		return nil
+	}
+	upper := make(map[string]V, len(m))
+	for k, v := range m {
+		upper[strings.ToUpper(k)] = v
+	}
+	return upper
+}
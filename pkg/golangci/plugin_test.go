@@ -0,0 +1,118 @@
+package golangci_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/golangci/plugin-module-register/register"
+	"golang.org/x/tools/go/analysis/analysistest"
+
+	_ "github.com/breml/depcaps/pkg/golangci"
+)
+
+func TestPlugin(t *testing.T) {
+	newPlugin, err := register.GetPlugin("depcaps")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatalf("Failed to get wd: %s", err)
+	}
+	testCaseDir := filepath.Join(filepath.Dir(filepath.Dir(wd)), "testdata", "src", "alltest")
+	err = os.Chdir(testCaseDir)
+	if err != nil {
+		t.Fatalf("Failed to change wd: %s", err)
+	}
+	t.Cleanup(func() {
+		err := os.Chdir(wd)
+		if err != nil {
+			t.Fatalf("Failed to return to wd: %s", err)
+		}
+	})
+
+	// golangci-lint passes the keys of the settings in lower case.
+	p, err := newPlugin(map[string]any{
+		"packageallowedcapabilities": map[string]any{
+			"github.com/google/uuid": map[string]any{
+				"capability_files": true,
+			},
+		},
+		"capslockbaselinefile": "allow/capslock.json",
+		"cachedir":             t.TempDir(),
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if p.GetLoadMode() != register.LoadModeTypesInfo {
+		t.Fatalf("expected load mode %q, got: %q", register.LoadModeTypesInfo, p.GetLoadMode())
+	}
+
+	analyzers, err := p.BuildAnalyzers()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(analyzers) != 1 || analyzers[0].Name != "depcaps" {
+		t.Fatalf("expected the depcaps analyzer, got: %v", analyzers)
+	}
+
+	analysistest.Run(t, testCaseDir, analyzers[0], "./allow/...")
+}
+
+func TestPluginError(t *testing.T) {
+	newPlugin, err := register.GetPlugin("depcaps")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	tt := []struct {
+		name     string
+		settings map[string]any
+	}{
+		{
+			name:     "unknown setting",
+			settings: map[string]any{"unknown": true},
+		},
+		{
+			name: "invalid capability",
+			settings: map[string]any{
+				"globalallowedcapabilities": map[string]any{"capability_invalid": true},
+			},
+		},
+		{
+			name: "invalid severity",
+			settings: map[string]any{
+				"globalcapabilityseverity": map[string]any{"capability_files": "critical"},
+			},
+		},
+		{
+			name: "config with inline settings",
+			settings: map[string]any{
+				"config":                    "../depcaps/testdata/ok.json",
+				"globalallowedcapabilities": map[string]any{"capability_files": true},
+			},
+		},
+		{
+			name: "config with inline approval",
+			settings: map[string]any{
+				"config":                  "../depcaps/testdata/ok.json",
+				"requireexplicitapproval": true,
+			},
+		},
+		{
+			name:     "config not found",
+			settings: map[string]any{"config": "notfound.json"},
+		},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := newPlugin(tc.settings)
+			if err == nil {
+				t.Fatalf("expected error, got none")
+			}
+		})
+	}
+}
@@ -1,12 +1,15 @@
 package module
 
 import (
+	"bufio"
 	"bytes"
+	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"os"
 	"os/exec"
+	"strings"
 
 	"golang.org/x/mod/modfile"
 )
@@ -19,30 +22,151 @@ type modInfo struct {
 	Main      bool   `json:"Main"`
 }
 
-// GetModuleFile gets module file.
-func GetModuleFile() (*modfile.File, error) {
+// GetModuleFile gets the module file of the main module of dir. An empty dir
+// is the working directory. The variables of env in the form key=value are
+// added to the environment of the go command, e.g. GOFLAGS=-modfile=old.mod.
+// The name of the syntax of the module file is the path of the go.mod file.
+func GetModuleFile(ctx context.Context, dir string, env []string) (*modfile.File, error) {
+	v, err := getModInfo(ctx, dir, env)
+	if err != nil {
+		return nil, err
+	}
+
+	raw, err := os.ReadFile(v.GoMod)
+	if err != nil {
+		return nil, fmt.Errorf("reading go.mod file: %w", err)
+	}
+
+	return modfile.Parse(v.GoMod, raw, nil)
+}
+
+// GetModuleSums gets the hashes from the go.sum file of the main module of
+// dir. The returned map is keyed by "path@version". Hashes of go.mod files
+// are omitted. With an alternate go.mod file, the go.sum file next to it is
+// used.
+func GetModuleSums(ctx context.Context, dir string, env []string) (map[string]string, error) {
+	v, err := getModInfo(ctx, dir, env)
+	if err != nil {
+		return nil, err
+	}
+
+	sums := make(map[string]string)
+
+	raw, err := os.ReadFile(strings.TrimSuffix(v.GoMod, ".mod") + ".sum")
+	if errors.Is(err, os.ErrNotExist) {
+		return sums, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("reading go.sum file: %w", err)
+	}
+
+	scanner := bufio.NewScanner(bytes.NewReader(raw))
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) != 3 || strings.HasSuffix(fields[1], "/go.mod") {
+			continue
+		}
+		sums[fields[0]+"@"+fields[1]] = fields[2]
+	}
+
+	return sums, scanner.Err()
+}
+
+// GetGoEnv gets the values of the given Go environment variables in dir with
+// the additional variables of env.
+func GetGoEnv(ctx context.Context, dir string, env []string, vars ...string) (map[string]string, error) {
+	args := append([]string{"env", "-json"}, vars...)
+	cmd := goCommand(ctx, dir, env, args...)
+
+	raw, err := cmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("command go env: %w: %s", err, string(raw))
+	}
+
+	values := make(map[string]string, len(vars))
+	err = json.Unmarshal(raw, &values)
+	if err != nil {
+		return nil, fmt.Errorf("unmarshaling error: %w: %s", err, string(raw))
+	}
+
+	return values, nil
+}
+
+// SplitGoFlags splits the value goflags of GOFLAGS into flags. Like in the go
+// command, a flag containing spaces is quoted with single or double quotes.
+func SplitGoFlags(goflags string) ([]string, error) {
+	var flags []string
+	for {
+		goflags = strings.TrimLeft(goflags, " \t\n\r")
+		if goflags == "" {
+			return flags, nil
+		}
+
+		if quote := goflags[0]; quote == '"' || quote == '\'' {
+			end := strings.IndexByte(goflags[1:], quote)
+			if end < 0 {
+				return nil, fmt.Errorf("unterminated %c string in GOFLAGS", quote)
+			}
+			flags = append(flags, goflags[1:end+1])
+			goflags = goflags[end+2:]
+			if goflags != "" && !strings.ContainsAny(goflags[:1], " \t\n\r") {
+				return nil, fmt.Errorf("unexpected %q after quoted flag in GOFLAGS", goflags[:1])
+			}
+			continue
+		}
+
+		end := strings.IndexAny(goflags, " \t\n\r")
+		if end < 0 {
+			end = len(goflags)
+		}
+		flags = append(flags, goflags[:end])
+		goflags = goflags[end:]
+	}
+}
+
+// QuoteGoFlag quotes flag for GOFLAGS, if it contains spaces.
+func QuoteGoFlag(flag string) (string, error) {
+	switch {
+	case !strings.ContainsAny(flag, " \t\n\r\"'"):
+		return flag, nil
+	case !strings.Contains(flag, "'"):
+		return "'" + flag + "'", nil
+	case !strings.Contains(flag, `"`):
+		return `"` + flag + `"`, nil
+	default:
+		return "", fmt.Errorf("flag %s contains both quotes and can not be used in GOFLAGS", flag)
+	}
+}
+
+func getModInfo(ctx context.Context, dir string, env []string) (modInfo, error) {
 	// https://github.com/golang/go/issues/44753#issuecomment-790089020
-	cmd := exec.Command("go", "list", "-m", "-json")
+	cmd := goCommand(ctx, dir, env, "list", "-m", "-json")
 
 	raw, err := cmd.Output()
 	if err != nil {
-		return nil, fmt.Errorf("command go list: %w: %s", err, string(raw))
+		return modInfo{}, fmt.Errorf("command go list: %w: %s", err, string(raw))
 	}
 
 	var v modInfo
 	err = json.NewDecoder(bytes.NewBuffer(raw)).Decode(&v)
 	if err != nil {
-		return nil, fmt.Errorf("unmarshaling error: %w: %s", err, string(raw))
+		return modInfo{}, fmt.Errorf("unmarshaling error: %w: %s", err, string(raw))
 	}
 
 	if v.GoMod == "" {
-		return nil, errors.New("working directory is not part of a module")
+		return modInfo{}, errors.New("working directory is not part of a module")
 	}
 
-	raw, err = os.ReadFile(v.GoMod)
-	if err != nil {
-		return nil, fmt.Errorf("reading go.mod file: %w", err)
-	}
+	return v, nil
+}
 
-	return modfile.Parse("go.mod", raw, nil)
+// goCommand returns the go command with args run in dir. The variables of env
+// override the variables of the environment of the process.
+func goCommand(ctx context.Context, dir string, env []string, args ...string) *exec.Cmd {
+	cmd := exec.CommandContext(ctx, "go", args...)
+	cmd.Dir = dir
+	if len(env) > 0 {
+		cmd.Env = append(os.Environ(), env...)
+	}
+	return cmd
 }
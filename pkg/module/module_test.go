@@ -1,7 +1,11 @@
 package module_test
 
 import (
+	"context"
+	"errors"
 	"os"
+	"path/filepath"
+	"strings"
 	"testing"
 
 	"github.com/breml/depcaps/pkg/module"
@@ -21,7 +25,7 @@ func TestGetModuleFile(t *testing.T) {
 		t.Fatalf("unexpected error: %v", err)
 	}
 
-	file, err := module.GetModuleFile()
+	file, err := module.GetModuleFile(context.Background(), "", nil)
 	if err != nil {
 		t.Fatalf("unexpected error: %v", err)
 	}
@@ -33,7 +37,7 @@ func TestGetModuleFile(t *testing.T) {
 }
 
 func TestGetModuleFile_here(t *testing.T) {
-	file, err := module.GetModuleFile()
+	file, err := module.GetModuleFile(context.Background(), "", nil)
 	if err != nil {
 		t.Fatalf("unexpected error: %v", err)
 	}
@@ -43,3 +47,109 @@ func TestGetModuleFile_here(t *testing.T) {
 		t.Fatalf("expected %q, got %q", expected, file.Module.Mod.Path)
 	}
 }
+
+func TestGetModuleSums(t *testing.T) {
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	defer func() {
+		_ = os.Chdir(wd)
+	}()
+
+	err = os.Chdir("./testdata/a/")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	sums, err := module.GetModuleSums(context.Background(), "", nil)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	expected := "h1:AWwleXJkX/nhcU9bZSnZoi3h/qGYqQAGhq6zZe/aQW8="
+	if expected != sums["github.com/gorilla/context@v1.1.1"] {
+		t.Fatalf("expected %q, got: %q", expected, sums["github.com/gorilla/context@v1.1.1"])
+	}
+
+	if _, ok := sums["github.com/stretchr/objx@v0.1.0"]; ok {
+		t.Fatalf("expected go.mod only entry to be omitted")
+	}
+}
+
+func TestGetModuleFile_dir(t *testing.T) {
+	file, err := module.GetModuleFile(context.Background(), "./testdata/a/", nil)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	expected := "github.com/breml/depcaps/testdata/a"
+	if expected != file.Module.Mod.Path {
+		t.Fatalf("expected %q, got: %q", expected, file.Module.Mod.Path)
+	}
+
+	if !filepath.IsAbs(file.Syntax.Name) || filepath.Base(file.Syntax.Name) != "go.mod" {
+		t.Fatalf("expected absolute path of go.mod as name, got: %q", file.Syntax.Name)
+	}
+}
+
+func TestGetModuleFile_canceled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	_, err := module.GetModuleFile(ctx, "", nil)
+	if err == nil {
+		t.Fatalf("expected error, got none")
+	}
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("expected context.Canceled, got: %v", err)
+	}
+}
+
+func TestGoFlags(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "with space")
+	flag, err := module.QuoteGoFlag("-modfile=" + filepath.Join(dir, "go.mod"))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	flags, err := module.SplitGoFlags("-mod=readonly  " + flag + "\t-tags=a,b")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	want := []string{"-mod=readonly", "-modfile=" + filepath.Join(dir, "go.mod"), "-tags=a,b"}
+	if strings.Join(flags, "|") != strings.Join(want, "|") {
+		t.Fatalf("expected flags %q, got: %q", want, flags)
+	}
+
+	// The go command reads the quoted flag as a single flag.
+	err = os.MkdirAll(dir, 0o755)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	err = os.WriteFile(filepath.Join(dir, "go.mod"), []byte("module quoted\n\ngo 1.21\n"), 0o600)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	moduleDir := t.TempDir()
+	err = os.WriteFile(filepath.Join(moduleDir, "go.mod"), []byte("module plain\n\ngo 1.21\n"), 0o600)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	mf, err := module.GetModuleFile(context.Background(), moduleDir, []string{"GOFLAGS=" + flag, "GOWORK=off"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if mf.Module.Mod.Path != "quoted" {
+		t.Fatalf("expected module quoted of the alternate go.mod file, got: %s", mf.Module.Mod.Path)
+	}
+
+	_, err = module.SplitGoFlags("'-modfile=a b")
+	if err == nil {
+		t.Fatalf("expected error for unterminated quote")
+	}
+	_, err = module.QuoteGoFlag(`-modfile=a '"b`)
+	if err == nil {
+		t.Fatalf("expected error for flag with both quotes")
+	}
+}
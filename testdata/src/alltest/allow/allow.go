@@ -4,9 +4,9 @@
 package main
 
 import (
-	"github.com/google/uuid" // want "Package github.com/google/uuid has not allowed capability CAPABILITY_NETWORK"
+	"github.com/google/uuid"
 )
 
 func main() {
-	uuid.GetTime()
+	uuid.GetTime() // want "Package github.com/google/uuid has not allowed capability CAPABILITY_NETWORK"
 }
@@ -0,0 +1,12 @@
+//go:build !go1.22
+// +build !go1.22
+
+package main
+
+import (
+	"github.com/google/uuid" //depcaps:allow CAPABILITY_FILES,CAPABILITY_NETWORK reason="time-based UUIDs"
+)
+
+func main() {
+	uuid.GetTime()
+}
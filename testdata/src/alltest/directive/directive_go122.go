@@ -0,0 +1,13 @@
+//go:build go1.22
+// +build go1.22
+
+package main
+
+import (
+	//depcaps:allow CAPABILITY_FILES,CAPABILITY_NETWORK,CAPABILITY_REFLECT reason="time-based UUIDs"
+	"github.com/google/uuid"
+)
+
+func main() {
+	uuid.GetTime()
+}
@@ -0,0 +1,8 @@
+package main
+
+import (
+	_ "fmt"     /* want "unused depcaps directive: package fmt has no not allowed capability CAPABILITY_FILES" */ //depcaps:allow CAPABILITY_FILES reason="formatting"
+	_ "os"      /* want "malformed depcaps directive: reason is required" */                                      //depcaps:allow CAPABILITY_FILES
+	_ "strings" /* want "malformed depcaps directive: unknown capability \"CAPABILITY_STRINGS\"" */               //depcaps:allow CAPABILITY_STRINGS reason="text"
+	_ "unicode" /* want "malformed depcaps directive: unknown directive \"deny\", expected allow" */              //depcaps:deny CAPABILITY_FILES reason="none"
+)
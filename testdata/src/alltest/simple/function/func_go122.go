@@ -4,9 +4,10 @@
 package function
 
 import (
-	"github.com/google/uuid" // want "Package github.com/google/uuid has not allowed capability CAPABILITY_FILES" "Package github.com/google/uuid has not allowed capability CAPABILITY_NETWORK" "Package github.com/google/uuid has not allowed capability CAPABILITY_REFLECT"
+	"github.com/google/uuid" // want "Package github.com/google/uuid has not allowed capability CAPABILITY_REFLECT"
 )
 
 func Call() {
-	uuid.GetTime() // regular function call is reported
+	// regular function call is reported
+	uuid.GetTime() // want "Package github.com/google/uuid has not allowed capability CAPABILITY_FILES" "Package github.com/google/uuid has not allowed capability CAPABILITY_NETWORK"
 }
@@ -1,9 +1,9 @@
 package method
 
 import (
-	"github.com/google/uuid" // want "Package github.com/google/uuid has not allowed capability CAPABILITY_REFLECT"
+	"github.com/google/uuid"
 )
 
 func Call() {
-	(&uuid.NullUUID{}).UnmarshalJSON(nil)
+	(&uuid.NullUUID{}).UnmarshalJSON(nil) // want "Package github.com/google/uuid has not allowed capability CAPABILITY_REFLECT"
 }